@@ -2,9 +2,17 @@ package main
 
 import (
 	"flag"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/keington/s3-csi-driver/driver"
+	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+	"github.com/keington/s3-csi-driver/driver/utils/mounthelper"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logsapi "k8s.io/component-base/logs/api/v1"
+	"k8s.io/klog/v2"
 )
 
 /**
@@ -15,30 +23,157 @@ import (
  */
 
 var (
+	configFile                   = flag.String("config", "", "YAML file of the driver options, named like the flags in camel case, e.g. mountTimeouts; flags given on the command line override it and changes of safe options are applied without a restart")
 	endpoint                     = flag.String("endpoint", "unix://tmp/csi.sock", "CSI endpoint")
+	tlsCertFile                  = flag.String("tls-cert-file", "", "PEM certificate chain served on a tcp:// endpoint, reloaded when it changes")
+	tlsKeyFile                   = flag.String("tls-key-file", "", "PEM private key of --tls-cert-file")
+	tlsClientCAFile              = flag.String("tls-client-ca-file", "", "PEM CA certificates verifying the client certificates required on a tcp:// endpoint; empty accepts any client")
+	insecureTCP                  = flag.Bool("insecure-tcp", false, "allow serving a tcp:// endpoint without TLS, anyone reaching it can call the driver")
+	mode                         = flag.String("mode", string(driver.ModeAll), "CSI services to serve: controller, node or all; the controller mode needs no mount privileges, the node and all modes require --nodeid")
 	nodeId                       = flag.String("nodeid", "", "node id")
 	mountPermissions             = flag.Uint64("mount-permissions", 0, "mounted folder permissions")
 	workingMountDir              = flag.String("working-mount-dir", "/tmp", "working directory for provisioner to mount nfs shares temporarily")
+	runtimeDir                   = flag.String("runtime-dir", mounter.DefaultRuntimeDir, "driver-owned directory for the per-mount credential files")
+	fuseRestartPolicy            = flag.String("fuse-restart-policy", string(mounter.RestartOnFailure), "restart policy of exited FUSE processes: Never, OnFailure or Always")
+	fuseMaxRestarts              = flag.Int("fuse-max-restarts", 5, "maximum number of restarts of a FUSE process, 0 means unlimited")
+	mountHelperEndpoint          = flag.String("mount-helper-endpoint", "", "unix socket of the mount helper running the FUSE processes, e.g. "+mounthelper.DefaultEndpoint+"; empty runs them in the driver")
+	enableEphemeral              = flag.Bool("enable-ephemeral", false, "enable ephemeral inline volumes, which requires the Ephemeral lifecycle mode in the CSIDriver object")
+	cacheRoot                    = flag.String("cache-root", driver.DefaultCacheRoot, "node directory holding the local caches of volumes with a cacheSize, empty disables caching")
+	cacheBudget                  = flag.String("cache-budget", "0", "total size of the volume caches on the node, e.g. 50Gi; 0 means unlimited")
+	defaultMounter               = flag.String("default-mounter", mounter.DefaultMounter, "mounter of the volumes which select none in their volume context or secrets")
+	deleteParallelism            = flag.Int("delete-parallelism", utils.DefaultDeleteParallelism, "number of objects removed at once when the objects of a volume are deleted one by one")
+	mountOptionsAllow            = flag.String("mount-options-allow", "", "comma separated mount options volumes may set, as name or mounter:name; empty allows every option known for the mounter")
+	mountOptionsDeny             = flag.String("mount-options-deny", "", "comma separated mount options volumes must not set, as name or mounter:name")
+	metricsAddress               = flag.String("metrics-address", "", "address of the Prometheus metrics endpoint, e.g. :9810; empty disables it")
+	otlpEndpoint                 = flag.String("otlp-endpoint", "", "host:port of the OTLP gRPC collector receiving the traces; empty disables tracing")
+	otlpInsecure                 = flag.Bool("otlp-insecure", false, "connect to the OTLP collector without TLS")
+	traceSampleRatio             = flag.Float64("trace-sample-ratio", 1, "share of the traces started by the driver which are sampled, between 0 and 1")
+	mountTimeout                 = flag.String("mount-timeout", "", "comma separated mount timeouts as duration or mounter=duration, e.g. 30s,rclone=1m; bounded by the deadline of the request")
+	requiredMounters             = flag.String("required-mounters", "", "comma separated mounters whose binaries the node must have, the probe fails without them; empty means the default mounter")
+	probeEndpoints               = flag.String("probe-endpoints", "", "comma separated S3 endpoint URLs the controller must reach, the probe fails if one is unreachable, e.g. https://s3.example.com; empty checks the endpoints of the secrets the controller was called with")
+	shutdownTimeout              = flag.Duration("shutdown-timeout", 20*time.Second, "how long in-flight calls are drained on SIGTERM before the ones still running checkpoint and are canceled; keep it below the termination grace period")
 	volStatsCacheExpireInMinutes = flag.Int("vol-stats-cache-expire-in-minutes", 10, "The cache expire time in minutes for volume stats cache")
 )
 
 func main() {
+	// --logging-format json logs one object per line with the request ID, method, volume ID and node ID of the calls
+	loggingConfig := logsapi.NewLoggingConfiguration()
+	utils.SetupLoggingFlags(loggingConfig)
 	flag.Parse()
+	if err := utils.ApplyLogging(loggingConfig); err != nil {
+		klog.Fatalf("invalid logging flags: %v", err)
+	}
 
 	driverOptions := driver.DriverOptions{
 		DriverName:                      "s3.csi.k8s.io",
+		Mode:                            *mode,
 		NodeID:                          *nodeId,
 		EndPoint:                        *endpoint,
+		TLSCertFile:                     *tlsCertFile,
+		TLSKeyFile:                      *tlsKeyFile,
+		TLSClientCAFile:                 *tlsClientCAFile,
+		InsecureTCP:                     *insecureTCP,
 		MountPermissions:                *mountPermissions,
 		WorkingMountDir:                 *workingMountDir,
+		RuntimeDir:                      *runtimeDir,
+		FuseRestartPolicy:               *fuseRestartPolicy,
+		FuseMaxRestarts:                 *fuseMaxRestarts,
+		MountHelperEndpoint:             *mountHelperEndpoint,
+		EnableEphemeral:                 *enableEphemeral,
+		CacheRoot:                       *cacheRoot,
+		CacheBudget:                     *cacheBudget,
+		DefaultMounter:                  *defaultMounter,
+		MountOptionsAllow:               splitList(*mountOptionsAllow),
+		MountOptionsDeny:                splitList(*mountOptionsDeny),
+		MountTimeouts:                   *mountTimeout,
+		DeleteParallelism:               *deleteParallelism,
+		MetricsAddress:                  *metricsAddress,
+		OTLPEndpoint:                    *otlpEndpoint,
+		OTLPInsecure:                    *otlpInsecure,
+		TraceSampleRatio:                *traceSampleRatio,
+		ShutdownTimeout:                 metav1.Duration{Duration: *shutdownTimeout},
+		RequiredMounters:                splitList(*requiredMounters),
+		ProbeEndpoints:                  splitList(*probeEndpoints),
 		VolumeStatsCacheExpireInMinutes: *volStatsCacheExpireInMinutes,
 	}
 
+	// The config file is overridden by the flags given on the command line, at start and on reloads.
+	options := &driverOptions
+	var config *driver.ConfigLoader
+	if *configFile != "" {
+		config = &driver.ConfigLoader{Path: *configFile, Defaults: driverOptions, Override: overrideFlags(driverOptions)}
+		loaded, err := config.Load()
+		if err != nil {
+			panic(err)
+		}
+		options = loaded
+	}
+
 	// Start the driver
-	driver, err := driver.NewDriver(&driverOptions)
+	d, err := driver.NewDriver(options)
 	if err != nil {
 		panic(err)
 	}
-	driver.Run(false)
-	os.Exit(0)
+	if config != nil {
+		d.WatchConfig(config)
+	}
+	d.Run()
+	klog.Flush()
+}
+
+// flagOptions maps the flags to the driver options they set
+var flagOptions = map[string]func(dst, flags *driver.DriverOptions){
+	"endpoint":              func(dst, flags *driver.DriverOptions) { dst.EndPoint = flags.EndPoint },
+	"tls-cert-file":         func(dst, flags *driver.DriverOptions) { dst.TLSCertFile = flags.TLSCertFile },
+	"tls-key-file":          func(dst, flags *driver.DriverOptions) { dst.TLSKeyFile = flags.TLSKeyFile },
+	"tls-client-ca-file":    func(dst, flags *driver.DriverOptions) { dst.TLSClientCAFile = flags.TLSClientCAFile },
+	"insecure-tcp":          func(dst, flags *driver.DriverOptions) { dst.InsecureTCP = flags.InsecureTCP },
+	"mode":                  func(dst, flags *driver.DriverOptions) { dst.Mode = flags.Mode },
+	"nodeid":                func(dst, flags *driver.DriverOptions) { dst.NodeID = flags.NodeID },
+	"mount-permissions":     func(dst, flags *driver.DriverOptions) { dst.MountPermissions = flags.MountPermissions },
+	"working-mount-dir":     func(dst, flags *driver.DriverOptions) { dst.WorkingMountDir = flags.WorkingMountDir },
+	"runtime-dir":           func(dst, flags *driver.DriverOptions) { dst.RuntimeDir = flags.RuntimeDir },
+	"fuse-restart-policy":   func(dst, flags *driver.DriverOptions) { dst.FuseRestartPolicy = flags.FuseRestartPolicy },
+	"fuse-max-restarts":     func(dst, flags *driver.DriverOptions) { dst.FuseMaxRestarts = flags.FuseMaxRestarts },
+	"mount-helper-endpoint": func(dst, flags *driver.DriverOptions) { dst.MountHelperEndpoint = flags.MountHelperEndpoint },
+	"enable-ephemeral":      func(dst, flags *driver.DriverOptions) { dst.EnableEphemeral = flags.EnableEphemeral },
+	"cache-root":            func(dst, flags *driver.DriverOptions) { dst.CacheRoot = flags.CacheRoot },
+	"cache-budget":          func(dst, flags *driver.DriverOptions) { dst.CacheBudget = flags.CacheBudget },
+	"default-mounter":       func(dst, flags *driver.DriverOptions) { dst.DefaultMounter = flags.DefaultMounter },
+	"delete-parallelism":    func(dst, flags *driver.DriverOptions) { dst.DeleteParallelism = flags.DeleteParallelism },
+	"mount-options-allow":   func(dst, flags *driver.DriverOptions) { dst.MountOptionsAllow = flags.MountOptionsAllow },
+	"mount-options-deny":    func(dst, flags *driver.DriverOptions) { dst.MountOptionsDeny = flags.MountOptionsDeny },
+	"metrics-address":       func(dst, flags *driver.DriverOptions) { dst.MetricsAddress = flags.MetricsAddress },
+	"otlp-endpoint":         func(dst, flags *driver.DriverOptions) { dst.OTLPEndpoint = flags.OTLPEndpoint },
+	"otlp-insecure":         func(dst, flags *driver.DriverOptions) { dst.OTLPInsecure = flags.OTLPInsecure },
+	"trace-sample-ratio":    func(dst, flags *driver.DriverOptions) { dst.TraceSampleRatio = flags.TraceSampleRatio },
+	"mount-timeout":         func(dst, flags *driver.DriverOptions) { dst.MountTimeouts = flags.MountTimeouts },
+	"required-mounters":     func(dst, flags *driver.DriverOptions) { dst.RequiredMounters = flags.RequiredMounters },
+	"probe-endpoints":       func(dst, flags *driver.DriverOptions) { dst.ProbeEndpoints = flags.ProbeEndpoints },
+	"shutdown-timeout":      func(dst, flags *driver.DriverOptions) { dst.ShutdownTimeout = flags.ShutdownTimeout },
+	"vol-stats-cache-expire-in-minutes": func(dst, flags *driver.DriverOptions) {
+		dst.VolumeStatsCacheExpireInMinutes = flags.VolumeStatsCacheExpireInMinutes
+	},
+}
+
+// overrideFlags returns a function setting the options of the flags given on the command line
+func overrideFlags(flags driver.DriverOptions) func(options *driver.DriverOptions) {
+	return func(options *driver.DriverOptions) {
+		flag.Visit(func(f *flag.Flag) {
+			if set, ok := flagOptions[f.Name]; ok {
+				set(options, &flags)
+			}
+		})
+	}
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+	"github.com/keington/s3-csi-driver/driver/utils/mounthelper"
+
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 19:28:52
+ * @file: main.go
+ * @description: 挂载守护进程，持有FUSE进程，使节点插件重启时挂载不中断
+ */
+
+var (
+	endpoint          = flag.String("endpoint", mounthelper.DefaultEndpoint, "mount helper unix socket")
+	logDir            = flag.String("log-dir", "/var/log/s3-csi", "directory for the per-mount logs of the FUSE processes")
+	fuseRestartPolicy = flag.String("fuse-restart-policy", string(mounter.RestartOnFailure), "restart policy of exited FUSE processes: Never, OnFailure or Always")
+	fuseMaxRestarts   = flag.Int("fuse-max-restarts", 5, "maximum number of restarts of a FUSE process, 0 means unlimited")
+)
+
+func main() {
+	flag.Parse()
+
+	switch mounter.RestartPolicy(*fuseRestartPolicy) {
+	case mounter.RestartNever, mounter.RestartOnFailure, mounter.RestartAlways:
+	default:
+		klog.Fatalf("invalid FUSE restart policy %q", *fuseRestartPolicy)
+	}
+
+	opts := mounter.DefaultSupervisorOptions()
+	opts.LogDir = *logDir
+	opts.RestartPolicy = mounter.RestartPolicy(*fuseRestartPolicy)
+	opts.MaxRestarts = *fuseMaxRestarts
+	server := mounthelper.NewServer(mounter.NewSupervisor(opts))
+
+	// The FUSE processes are left running on shutdown, the mounts stay usable until they are unpublished.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		klog.Infof("Received %v, stopping mount helper", sig)
+		server.Stop()
+	}()
+
+	if err := server.Serve(*endpoint); err != nil {
+		klog.Fatalf("Failed to serve mount helper: %v", err)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,346 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 20:02:37
+ * @file: cache.go
+ * @description: 节点本地磁盘缓存管理，按卷分配目录、限制总量并淘汰
+ */
+
+const (
+	// DefaultCacheRoot is the default node directory holding the volume caches
+	DefaultCacheRoot = "/var/lib/s3-csi/cache"
+	// cacheStateFile records the reservations, so they survive restarts of the driver
+	cacheStateFile = ".reservations.json"
+	// cacheSweepInterval is the interval of the eviction of caches the mounter does not limit itself
+	cacheSweepInterval = time.Minute
+	// cacheEvictRatio is the share of the cache size kept after an eviction, leaving room for new data
+	cacheEvictRatio = 0.9
+)
+
+// cacheDirRegexp matches the names of cache directories, which cannot escape the cache root
+var cacheDirRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]{0,127}$`)
+
+// cacheReservation is the cache of the volume mounted at a target
+type cacheReservation struct {
+	VolumeID string `json:"volumeID"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	// Evict is set for mounters which do not limit their cache themselves.
+	Evict bool `json:"evict"`
+}
+
+// cacheManager allocates the per-volume cache directories under the node cache root
+// and keeps their total size within the node cache budget.
+type cacheManager struct {
+	mu   sync.Mutex
+	root string
+	// budget is the total size of the caches in bytes, 0 means unlimited
+	budget int64
+	// reservations maps the mount targets to their caches
+	reservations map[string]*cacheReservation
+}
+
+// newCacheManager creates a cache manager and loads the reservations of an earlier instance of the driver
+func newCacheManager(root string, budget int64) (*cacheManager, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache root %s: %w", root, err)
+	}
+	c := &cacheManager{
+		root:         root,
+		budget:       budget,
+		reservations: make(map[string]*cacheReservation),
+	}
+	data, err := os.ReadFile(filepath.Join(root, cacheStateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &c.reservations); err != nil {
+			return nil, fmt.Errorf("failed to load cache reservations: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// SetBudget changes the total size of the caches, the reserved caches are kept if they exceed it
+func (c *cacheManager) SetBudget(budget int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+}
+
+// mountedPaths returns the mount points of the node
+func mountedPaths() (map[string]bool, error) {
+	mountPoints, err := mount.New("").List()
+	if err != nil {
+		return nil, err
+	}
+	mounted := make(map[string]bool, len(mountPoints))
+	for _, mp := range mountPoints {
+		mounted[mp.Path] = true
+	}
+	return mounted, nil
+}
+
+// validateCacheDir returns an error if name cannot be used as cache directory
+func validateCacheDir(name string) error {
+	if !cacheDirRegexp.MatchString(name) {
+		return fmt.Errorf("invalid %s %q: must be a directory name of letters, digits, '_', '.' and '-'", ParamCacheDir, name)
+	}
+	return nil
+}
+
+// volumeCacheDir returns the default name of the cache directory of a volume
+func volumeCacheDir(volumeID string) string {
+	sum := sha256.Sum256([]byte(volumeID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Reserve allocates a cache of size bytes for the volume mounted at target and returns its directory.
+// The cache is named name, or after the volume if name is empty.
+func (c *cacheManager) Reserve(target, volumeID, name string, size int64, evict bool) (string, error) {
+	if name == "" {
+		name = volumeCacheDir(volumeID)
+	} else if err := validateCacheDir(name); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.reservations[target]; ok {
+		if r.Name != name || r.Size != size {
+			return "", status.Errorf(codes.FailedPrecondition, "a cache %s of %d bytes is already reserved for %s", r.Name, r.Size, target)
+		}
+		return filepath.Join(c.root, name), nil
+	}
+	var reserved int64
+	for t, r := range c.reservations {
+		if r.Name == name {
+			return "", status.Errorf(codes.FailedPrecondition, "cache directory %s is already used by volume %s at %s", name, r.VolumeID, t)
+		}
+		reserved += r.Size
+	}
+	if c.budget > 0 && reserved+size > c.budget {
+		return "", status.Errorf(codes.ResourceExhausted, "cache of %d bytes exceeds the node cache budget, %d of %d bytes are reserved", size, reserved, c.budget)
+	}
+
+	dir := filepath.Join(c.root, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", status.Errorf(codes.Internal, "failed to create cache directory %s: %v", dir, err)
+	}
+	c.reservations[target] = &cacheReservation{VolumeID: volumeID, Name: name, Size: size, Evict: evict}
+	if err := c.save(); err != nil {
+		delete(c.reservations, target)
+		return "", status.Errorf(codes.Internal, "failed to save cache reservations: %v", err)
+	}
+	return dir, nil
+}
+
+// Release deletes the cache of the volume mounted at target, if it has one
+func (c *cacheManager) Release(target string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.reservations[target]
+	if !ok {
+		return nil
+	}
+	if err := os.RemoveAll(filepath.Join(c.root, r.Name)); err != nil {
+		return fmt.Errorf("failed to delete cache %s: %w", r.Name, err)
+	}
+	delete(c.reservations, target)
+	return c.save()
+}
+
+// Usage returns the used and reserved bytes of the cache of the volume mounted at target
+func (c *cacheManager) Usage(target string) (used, size int64, ok bool, err error) {
+	c.mu.Lock()
+	r, ok := c.reservations[target]
+	c.mu.Unlock()
+	if !ok {
+		return 0, 0, false, nil
+	}
+	used, err = dirUsage(filepath.Join(c.root, r.Name))
+	return used, r.Size, true, err
+}
+
+// TotalUsage returns the used and reserved bytes of all caches
+func (c *cacheManager) TotalUsage() (used, reserved int64) {
+	c.mu.Lock()
+	names := make(map[string]int64, len(c.reservations))
+	for _, r := range c.reservations {
+		names[r.Name] = r.Size
+	}
+	c.mu.Unlock()
+	for name, size := range names {
+		reserved += size
+		if u, err := dirUsage(filepath.Join(c.root, name)); err == nil {
+			used += u
+		}
+	}
+	return used, reserved
+}
+
+// GarbageCollect drops the caches of targets which are not mounted anymore
+// and the directories without a reservation.
+func (c *cacheManager) GarbageCollect(ctx context.Context, mounted map[string]bool) error {
+	logger := klog.FromContext(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make(map[string]bool)
+	for target, r := range c.reservations {
+		if mounted[target] {
+			names[r.Name] = true
+			continue
+		}
+		logger.Info("Deleting cache of a volume which is not mounted anymore", "cache", r.Name, "volumeID", r.VolumeID, "target", target)
+		delete(c.reservations, target)
+	}
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || names[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.root, entry.Name())); err != nil {
+			logger.Error(err, "Failed to delete cache", "cache", entry.Name())
+		}
+	}
+	return c.save()
+}
+
+// run evicts the least recently used files of the caches whose mounter does not limit them
+func (c *cacheManager) run(ctx context.Context, interval time.Duration) {
+	for range time.Tick(interval) {
+		c.evict(ctx)
+	}
+}
+
+// evict shrinks the caches which exceed their size
+func (c *cacheManager) evict(ctx context.Context) {
+	c.mu.Lock()
+	var caches []cacheReservation
+	for _, r := range c.reservations {
+		if r.Evict && r.Size > 0 {
+			caches = append(caches, *r)
+		}
+	}
+	c.mu.Unlock()
+	for _, r := range caches {
+		logger := klog.LoggerWithValues(klog.FromContext(ctx), "cache", r.Name, "volumeID", r.VolumeID)
+		if err := evictFiles(klog.NewContext(ctx, logger), filepath.Join(c.root, r.Name), int64(float64(r.Size)*cacheEvictRatio), r.Size); err != nil {
+			logger.Error(err, "Failed to evict cache")
+		}
+	}
+}
+
+// save writes the reservations to the state file, c.mu must be held
+func (c *cacheManager) save() error {
+	data, err := json.Marshal(c.reservations)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.root, cacheStateFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cachedFile is a file of a cache considered for eviction
+type cachedFile struct {
+	path  string
+	size  int64
+	atime time.Time
+}
+
+// evictFiles deletes the least recently accessed files of dir down to keep bytes once it exceeds limit bytes
+func evictFiles(ctx context.Context, dir string, keep, limit int64) error {
+	var files []cachedFile
+	var used int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// files may be deleted by the mounter meanwhile
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		f := cachedFile{path: path, size: info.Size(), atime: info.ModTime()}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			f.atime = time.Unix(st.Atim.Sec, st.Atim.Nsec)
+		}
+		files = append(files, f)
+		used += f.size
+		return nil
+	})
+	if err != nil || used <= limit {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].atime.Before(files[j].atime) })
+	for _, f := range files {
+		if used <= keep {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		used -= f.size
+	}
+	klog.FromContext(ctx).V(4).Info("Evicted cache", "dir", dir, "bytes", used)
+	return nil
+}
+
+// dirUsage returns the size of the regular files in dir
+func dirUsage(dir string) (int64, error) {
+	var used int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// files may be deleted by the mounter meanwhile
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			used += info.Size()
+		}
+		return nil
+	})
+	return used, err
+}
@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 20:31:14
+ * @file: cache_test.go
+ * @description: cache 单测
+ */
+
+func TestCacheManagerReserve(t *testing.T) {
+	root := t.TempDir()
+	c, err := newCacheManager(root, 3<<20)
+	if err != nil {
+		t.Fatalf("newCacheManager() error = %v", err)
+	}
+
+	dir, err := c.Reserve("/staging/a", "bucket/a", "", 2<<20, false)
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if want := filepath.Join(root, volumeCacheDir("bucket/a")); dir != want {
+		t.Errorf("Reserve() = %s, want %s", dir, want)
+	}
+	if again, err := c.Reserve("/staging/a", "bucket/a", "", 2<<20, false); err != nil || again != dir {
+		t.Errorf("Reserve() again = %s, %v, want %s", again, err, dir)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		cacheDir string
+		size     int64
+		wantCode codes.Code
+	}{
+		{
+			name:     "Test budget exceeded",
+			target:   "/staging/b",
+			size:     2 << 20,
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "Test directory used by another volume",
+			target:   "/staging/b",
+			cacheDir: volumeCacheDir("bucket/a"),
+			size:     1 << 20,
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Test directory escaping the cache root",
+			target:   "/staging/b",
+			cacheDir: "../etc",
+			size:     1 << 20,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Test different size for the same target",
+			target:   "/staging/a",
+			size:     1 << 20,
+			wantCode: codes.FailedPrecondition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.Reserve(tt.target, "bucket/b", tt.cacheDir, tt.size, false)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("Reserve() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+
+	// the reservations survive a restart
+	restarted, err := newCacheManager(root, 3<<20)
+	if err != nil {
+		t.Fatalf("newCacheManager() error = %v", err)
+	}
+	if _, size, ok, err := restarted.Usage("/staging/a"); !ok || err != nil || size != 2<<20 {
+		t.Errorf("Usage() after restart = %d, %v, %v", size, ok, err)
+	}
+
+	if err := restarted.Release("/staging/a"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Release() did not delete %s", dir)
+	}
+	if _, err := restarted.Reserve("/staging/b", "bucket/b", "shared", 3<<20, false); err != nil {
+		t.Errorf("Reserve() after Release() error = %v", err)
+	}
+}
+
+func TestCacheManagerGarbageCollect(t *testing.T) {
+	root := t.TempDir()
+	c, err := newCacheManager(root, 0)
+	if err != nil {
+		t.Fatalf("newCacheManager() error = %v", err)
+	}
+	active, err := c.Reserve("/staging/active", "bucket/active", "", 1<<20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := c.Reserve("/staging/stale", "bucket/stale", "", 1<<20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan := filepath.Join(root, "orphan")
+	if err := os.Mkdir(orphan, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.GarbageCollect(context.Background(), map[string]bool{"/staging/active": true}); err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("GarbageCollect() deleted the cache of a mounted volume: %v", err)
+	}
+	for _, dir := range []string{stale, orphan} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("GarbageCollect() did not delete %s", dir)
+		}
+	}
+	if _, _, ok, _ := c.Usage("/staging/stale"); ok {
+		t.Errorf("GarbageCollect() kept the reservation of an unmounted volume")
+	}
+}
+
+func TestEvictFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"oldest", "older", "newest"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100), 0600); err != nil {
+			t.Fatal(err)
+		}
+		accessed := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(path, accessed, accessed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := evictFiles(context.Background(), dir, 150, 250); err != nil {
+		t.Fatalf("evictFiles(context.Background(), ) error = %v", err)
+	}
+	for name, wantExist := range map[string]bool{"oldest": false, "older": false, "newest": true} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exist := err == nil; exist != wantExist {
+			t.Errorf("evictFiles(context.Background(), ) %s exists = %v, want %v", name, exist, wantExist)
+		}
+	}
+	if used, err := dirUsage(dir); err != nil || used != 100 {
+		t.Errorf("dirUsage() = %d, %v, want 100", used, err)
+	}
+}
@@ -0,0 +1,194 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 02:10:37
+ * @file: config.go
+ * @description: 配置文件，命令行参数优先，运行中按内容变化热加载可变更的选项
+ */
+
+// configPollInterval is the interval of the checks of the config file for changes.
+var configPollInterval = 10 * time.Second
+
+// reloadableOptions are the options, by their name in the config file, which take effect
+// without a restart when the config file changes.
+var reloadableOptions = map[string]bool{
+	"fuseRestartPolicy": true,
+	"fuseMaxRestarts":   true,
+	"cacheBudget":       true,
+	"defaultMounter":    true,
+	"mountOptionsAllow": true,
+	"mountOptionsDeny":  true,
+	"mountTimeouts":     true,
+	"deleteParallelism": true,
+	"requiredMounters":  true,
+	"probeEndpoints":    true,
+}
+
+// ConfigLoader reads the driver options from a YAML config file, the flags given on the
+// command line override the file.
+type ConfigLoader struct {
+	// Path is the config file.
+	Path string
+	// Defaults are the options of the settings missing in the file.
+	Defaults DriverOptions
+	// Override sets the options given on the command line, nil if there are none.
+	Override func(options *DriverOptions)
+
+	// data is the content of the config file when it was read last
+	data []byte
+}
+
+// Load reads the options from the config file. Unknown settings are rejected, the values
+// are validated by NewDriver.
+func (l *ConfigLoader) Load() (*DriverOptions, error) {
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	options, err := l.parse(data)
+	if err != nil {
+		return nil, err
+	}
+	l.data = data
+	return options, nil
+}
+
+// parse returns the options of the config file content on top of the defaults, overridden by the flags
+func (l *ConfigLoader) parse(data []byte) (*DriverOptions, error) {
+	options := l.Defaults.deepCopy()
+	if err := yaml.UnmarshalStrict(data, &options); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", l.Path, err)
+	}
+	if l.Override != nil {
+		l.Override(&options)
+	}
+	return &options, nil
+}
+
+// deepCopy returns a copy of the options which shares no slice with them, the config file
+// is decoded into the slices of the options it is decoded on top of.
+func (o DriverOptions) deepCopy() DriverOptions {
+	o.MountOptionsAllow = slices.Clone(o.MountOptionsAllow)
+	o.MountOptionsDeny = slices.Clone(o.MountOptionsDeny)
+	o.RequiredMounters = slices.Clone(o.RequiredMounters)
+	o.ProbeEndpoints = slices.Clone(o.ProbeEndpoints)
+	return o
+}
+
+// optionChange is an option whose value differs between two configurations
+type optionChange struct {
+	name     string
+	index    int
+	old, new interface{}
+}
+
+// diffOptions returns the options changed from old to new, named as in the config file
+func diffOptions(old, new *DriverOptions) []optionChange {
+	var changes []optionChange
+	oldValue, newValue := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		o, n := oldValue.Field(i), newValue.Field(i)
+		// an empty list in the file is no change from a missing one
+		if reflect.DeepEqual(o.Interface(), n.Interface()) || (o.Kind() == reflect.Slice && o.Len() == 0 && n.Len() == 0) {
+			continue
+		}
+		name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("json"), ",")
+		changes = append(changes, optionChange{name: name, index: i, old: o.Interface(), new: n.Interface()})
+	}
+	return changes
+}
+
+// WatchConfig makes Run reload the options from the config file of loader when it changes.
+func (d *Driver) WatchConfig(loader *ConfigLoader) {
+	d.config = loader
+}
+
+// watchConfig polls the config file until ctx is done and reloads the options when its content changes.
+// The content is compared since a mounted ConfigMap is updated by swapping a symlink.
+func (d *Driver) watchConfig(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(d.config.Path)
+		if err != nil {
+			klog.Warningf("Failed to read config file %s: %v", d.config.Path, err)
+			continue
+		}
+		if bytes.Equal(data, d.config.data) {
+			continue
+		}
+		options, err := d.config.parse(data)
+		if err == nil {
+			err = d.reload(options)
+		}
+		if err != nil {
+			// the content is not recorded, so the file is read again until it is fixed
+			klog.Errorf("Ignoring the changes of config file %s: %v", d.config.Path, err)
+			continue
+		}
+		d.config.data = data
+	}
+}
+
+// reload applies the changed options which can change while the driver runs and logs every
+// change, the other changes take effect at the next start. Invalid options change nothing.
+func (d *Driver) reload(options *DriverOptions) error {
+	if _, err := parseOptions(options); err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	next := d.options.deepCopy()
+	d.mu.RUnlock()
+	current := reflect.ValueOf(&next).Elem()
+	for _, c := range diffOptions(&next, options) {
+		if !reloadableOptions[c.name] {
+			klog.Warningf("Option %s changed from %v to %v, it takes effect after a restart", c.name, c.old, c.new)
+			continue
+		}
+		klog.Infof("Option %s changed from %v to %v", c.name, c.old, c.new)
+		current.Field(c.index).Set(reflect.ValueOf(c.new))
+	}
+
+	// the options which cannot change keep the values the driver started with
+	reloaded, err := parseOptions(&next)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.options = next
+	d.FuseRestartPolicy = reloaded.FuseRestartPolicy
+	d.FuseMaxRestarts = reloaded.FuseMaxRestarts
+	d.CacheBudget = reloaded.CacheBudget
+	d.DefaultMounter = reloaded.DefaultMounter
+	d.MountOptionsAllow = reloaded.MountOptionsAllow
+	d.MountOptionsDeny = reloaded.MountOptionsDeny
+	d.MountTimeouts = reloaded.MountTimeouts
+	d.DeleteParallelism = reloaded.DeleteParallelism
+	d.RequiredMounters = reloaded.RequiredMounters
+	d.ProbeEndpoints = reloaded.ProbeEndpoints
+	d.mu.Unlock()
+	d.applySettings()
+	return nil
+}
@@ -0,0 +1,231 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 02:24:52
+ * @file: config_test.go
+ * @description: config 单测
+ */
+
+func TestConfigLoader(t *testing.T) {
+	defaults := DriverOptions{
+		DriverName:        DefaultDriverName,
+		Mode:              string(ModeAll),
+		EndPoint:          "unix://tmp/csi.sock",
+		MountOptionsAllow: []string{"allow_other"},
+		ShutdownTimeout:   metav1.Duration{Duration: 20 * time.Second},
+	}
+	tests := []struct {
+		name     string
+		config   string
+		override func(options *DriverOptions)
+		want     func(options *DriverOptions)
+		wantErr  bool
+	}{
+		{
+			name: "Test file",
+			config: `
+mode: node
+nodeID: node-1
+mountOptionsAllow: [ro, "rclone:vfs-cache-mode"]
+mountTimeouts: 30s,rclone=1m
+shutdownTimeout: 45s
+`,
+			want: func(o *DriverOptions) {
+				o.Mode, o.NodeID = string(ModeNode), "node-1"
+				o.MountOptionsAllow = []string{"ro", "rclone:vfs-cache-mode"}
+				o.MountTimeouts = "30s,rclone=1m"
+				o.ShutdownTimeout.Duration = 45 * time.Second
+			},
+		},
+		{
+			name:     "Test flags override file",
+			config:   "mode: node\nnodeID: node-1\n",
+			override: func(o *DriverOptions) { o.NodeID = "node-2" },
+			want:     func(o *DriverOptions) { o.Mode, o.NodeID = string(ModeNode), "node-2" },
+		},
+		{name: "Test unknown option", config: "mountTimeout: 30s\n", wantErr: true},
+		{name: "Test invalid value", config: "shutdownTimeout: soon\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			loader := &ConfigLoader{Path: path, Defaults: defaults, Override: tt.override}
+			got, err := loader.Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := defaults.deepCopy()
+			tt.want(&want)
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("Load() = %+v, want %+v", *got, want)
+			}
+			if !reflect.DeepEqual(loader.Defaults.MountOptionsAllow, []string{"allow_other"}) {
+				t.Errorf("Load() changed the defaults to %v", loader.Defaults.MountOptionsAllow)
+			}
+		})
+	}
+}
+
+func TestDiffOptions(t *testing.T) {
+	old := &DriverOptions{Mode: string(ModeNode), MountTimeouts: "30s"}
+	new := &DriverOptions{Mode: string(ModeNode), MountTimeouts: "1m", MountOptionsDeny: []string{}, FuseMaxRestarts: 3}
+	var got []string
+	for _, c := range diffOptions(old, new) {
+		got = append(got, c.name)
+	}
+	if want := []string{"fuseMaxRestarts", "mountTimeouts"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("diffOptions() = %v, want %v", got, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	defer mounter.SetOptionPolicy(nil, nil)
+	defer mounter.SetDefaultMounter(mounter.DefaultMounter)
+	defer utils.SetDeleteParallelism(utils.DefaultDeleteParallelism)
+
+	options := &DriverOptions{DriverName: DefaultDriverName, Mode: string(ModeController), NodeID: "controller-1"}
+	d, err := NewDriver(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.IdentityServer = NewIdentityServer(d)
+
+	// invalid options change nothing
+	invalid := options.deepCopy()
+	invalid.DefaultMounter = "ntfs"
+	if err := d.reload(&invalid); err == nil {
+		t.Errorf("reload() accepted the invalid default mounter %s", invalid.DefaultMounter)
+	}
+	if d.DefaultMounter != mounter.DefaultMounter {
+		t.Errorf("reload() of invalid options changed the default mounter to %s", d.DefaultMounter)
+	}
+
+	changed := options.deepCopy()
+	changed.Mode = string(ModeAll)
+	changed.DefaultMounter = "rclone"
+	changed.MountOptionsDeny = []string{"transfers"}
+	changed.DeleteParallelism = 4
+	if err := d.reload(&changed); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if d.DefaultMounter != "rclone" || !reflect.DeepEqual(d.MountOptionsDeny, []string{"transfers"}) || d.DeleteParallelism != 4 {
+		t.Errorf("reload() did not apply the safe options: %s, %v, %d", d.DefaultMounter, d.MountOptionsDeny, d.DeleteParallelism)
+	}
+	if d.Mode != ModeController || d.options.Mode != string(ModeController) {
+		t.Errorf("reload() changed the mode to %s, it needs a restart", d.Mode)
+	}
+	// the options of volumes selecting no mounter are the ones of rclone now
+	if _, err := mounter.ParseOptions("", []string{"--no-modtime"}); err != nil {
+		t.Errorf("reload() did not apply the default mounter: %v", err)
+	}
+	if _, err := mounter.ParseOptions("", []string{"--transfers=4"}); err == nil {
+		t.Errorf("reload() did not apply the mount option policy")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	defer mounter.SetOptionPolicy(nil, nil)
+	defer mounter.SetDefaultMounter(mounter.DefaultMounter)
+	defer utils.SetDeleteParallelism(utils.DefaultDeleteParallelism)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	valid := []byte("mode: controller\nnodeID: controller-1\n")
+	if err := os.WriteFile(path, valid, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loader := &ConfigLoader{Path: path, Defaults: DriverOptions{DriverName: DefaultDriverName}}
+	options, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDriver(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.IdentityServer = NewIdentityServer(d)
+	d.WatchConfig(loader)
+
+	// watch runs watchConfig until the file was polled a few times
+	watch := func(config string) {
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			d.watchConfig(ctx, 5*time.Millisecond)
+			close(done)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+	}
+
+	watch("mode: controller\nnodeID: controller-1\ndefaultMounter: ntfs\n")
+	if !bytes.Equal(loader.data, valid) {
+		t.Errorf("watchConfig() recorded the content of a rejected config file")
+	}
+	watch("mode: controller\nnodeID: controller-1\ndefaultMounter: rclone\n")
+	if d.DefaultMounter != "rclone" || bytes.Equal(loader.data, valid) {
+		t.Errorf("watchConfig() did not reload the fixed config file, default mounter %s", d.DefaultMounter)
+	}
+}
+
+func TestReloadConcurrently(t *testing.T) {
+	defer mounter.SetOptionPolicy(nil, nil)
+	defer mounter.SetDefaultMounter(mounter.DefaultMounter)
+	defer utils.SetDeleteParallelism(utils.DefaultDeleteParallelism)
+
+	options := &DriverOptions{DriverName: DefaultDriverName, Mode: string(ModeController), NodeID: "controller-1"}
+	d, err := NewDriver(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.IdentityServer = NewIdentityServer(d)
+
+	// the race detector reports unguarded settings
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			changed := options.deepCopy()
+			changed.DeleteParallelism = i + 1
+			changed.ProbeEndpoints = []string{"http://127.0.0.1:9000"}
+			if err := d.reload(&changed); err != nil {
+				t.Errorf("reload() error = %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			NewIdentityServer(d)
+			d.applySettings()
+		}
+	}()
+	wg.Wait()
+}
@@ -2,11 +2,14 @@ package driver
 
 import (
 	"context"
-
+	"errors"
+	"fmt"
 	"path"
+	"slices"
 	"strings"
 
 	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	common "github.com/kubernetes-csi/drivers/pkg/csi-common"
@@ -24,6 +27,12 @@ import (
 
 type ControllerServer struct {
 	*common.DefaultControllerServer
+	// volumeLocks serializes the operations on a volume.
+	volumeLocks *utils.VolumeLocks
+	// capabilities are the RPCs of the controller service the driver supports.
+	capabilities []*csi.ControllerServiceCapability
+	// endpoints records the S3 endpoints of the requests for the health checks.
+	endpoints *endpointSet
 }
 
 // NewControllerServiceCapability creates a new ControllerServiceCapability
@@ -38,14 +47,14 @@ func NewControllerServiceCapability(cap csi.ControllerServiceCapability_RPC_Type
 }
 
 // CreateVolume implements csi.ControllerServer.
-func (c *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+func (c *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
 	volumeId := req.GetName()
 	params := req.GetParameters()
 	bucketName := volumeId
 	prefix := ""
 
 	if err := c.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME); err != nil {
-		klog.V(2).Infof("ValidateControllerServiceRequest: invalid create volume request: %v", req)
+		klog.FromContext(ctx).V(2).Info("Invalid create volume request", "err", err)
 		return nil, err
 	}
 
@@ -58,16 +67,33 @@ func (c *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolume
 		prefix = volumeId
 		volumeId = path.Join(bucketName, prefix)
 	}
+	// lock the final bucket/prefix volume ID, which is the ID DeleteVolume and ValidateVolumeCapabilities lock
+	if acquired := c.volumeLocks.TryAcquire(volumeId); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, volumeId)
+	}
+	defer c.volumeLocks.Release(volumeId)
 	if req.GetVolumeCapabilities() == nil {
 		return nil, status.Error(codes.InvalidArgument, "CreateVolume: volume capabilities is missing")
 	}
+	// the parameters become the volume context, reject the ones the node would refuse to mount
+	if _, err := getMeta(bucketName, prefix, params, 0); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+	}
+	for _, capability := range req.GetVolumeCapabilities() {
+		if _, _, err := parseMountFlags(params["mounter"], capability.GetMount().GetMountFlags()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+		}
+	}
 
 	capacityBytes := int64(req.GetCapacityRange().GetRequiredBytes())
 
-	klog.V(4).Infof("CreateVolume: volumeId %s, capacityBytes %d", volumeId, capacityBytes)
+	// the volume ID is only known from here on, log it with the S3 requests too
+	logger := klog.LoggerWithValues(klog.FromContext(ctx), "volumeID", volumeId)
+	ctx = klog.NewContext(ctx, logger)
+	logger.V(4).Info("Creating volume", "bucket", bucketName, "prefix", prefix, "capacityBytes", capacityBytes)
 
 	// create s3 client and create bucket
-	client, err := utils.NewClientFromSecrets(req.GetSecrets())
+	client, err := c.newClient(ctx, req.GetSecrets())
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "CreateVolume: failed to initialize s3 client: %s", err.Error())
 	}
@@ -86,9 +112,13 @@ func (c *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolume
 		}
 	}
 
-	klog.V(4).Infof("CreateVolume: volumeId %s, capacityBytes %d", volumeId, capacityBytes)
+	logger.V(4).Info("Created volume", "bucket", bucketName, "prefix", prefix, "capacityBytes", capacityBytes)
 
-	context := make(map[string]string)
+	// pass the StorageClass parameters on to the node, e.g. mounter and ownership options
+	context := make(map[string]string, len(params))
+	for k, v := range params {
+		context[k] = v
+	}
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:      volumeId,
@@ -99,7 +129,7 @@ func (c *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolume
 }
 
 // DeleteVolume implements csi.ControllerServer.
-func (c *ControllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+func (c *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
 	volumeId := req.GetVolumeId()
 	bucketName, prefix := volumeIDToBucketPrefix(volumeId)
 
@@ -107,15 +137,20 @@ func (c *ControllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolume
 	if len(volumeId) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "DeleteVolume: volume ID is missing")
 	}
+	if acquired := c.volumeLocks.TryAcquire(volumeId); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, volumeId)
+	}
+	defer c.volumeLocks.Release(volumeId)
+	logger := klog.FromContext(ctx)
 	if err := c.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME); err != nil {
-		klog.V(2).Infof("ValidateControllerServiceRequest: invalid delete volume request: %v", req)
+		logger.V(2).Info("Invalid delete volume request", "err", err)
 		return nil, err
 	}
 
-	klog.V(4).Infof("DeleteVolume: volumeId %s", volumeId)
+	logger.V(4).Info("Deleting volume", "bucket", bucketName, "prefix", prefix)
 
 	// create s3 client and delete bucket
-	client, err := utils.NewClientFromSecrets(req.GetSecrets())
+	client, err := c.newClient(ctx, req.GetSecrets())
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "DeleteVolume: failed to initialize s3 client: %s", err.Error())
 	}
@@ -124,22 +159,28 @@ func (c *ControllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolume
 		if err := client.DeleteBucket(bucketName); err != nil {
 			deleteErr = err
 		}
-		klog.V(4).Infof("DeleteVolume: prefix %s is deleted", prefix)
 	} else {
-		if err := client.DeletePrefix(bucketName, prefix); err != nil {
+		if err := client.DeletePrefix(bucketName, prefix); errors.Is(err, utils.ErrShuttingDown) {
+			deleteErr = err
+		} else if err != nil {
 			deleteErr = status.Errorf(codes.Internal, "DeleteVolume: failed to delete bucket %s: %s", prefix, err.Error())
 		}
-		klog.V(4).Infof("DeleteVolume: prefix %s is deleted", prefix)
 	}
 
+	if errors.Is(deleteErr, utils.ErrShuttingDown) {
+		// the deleted objects are gone, the retry after the restart deletes the rest
+		logger.Info("Interrupted volume deletion for shutdown", "bucket", bucketName, "prefix", prefix)
+		return nil, status.Errorf(codes.Unavailable, "DeleteVolume: %v", deleteErr)
+	}
 	if deleteErr != nil {
 		return nil, deleteErr
 	}
+	logger.V(4).Info("Deleted volume", "bucket", bucketName, "prefix", prefix)
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
 // ValidateVolumeCapabilities implements csi.ControllerServer.
-func (c *ControllerServer) ValidateVolumeCapabilities(_ context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+func (c *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
 	// check arguments
 	if len(req.GetVolumeId()) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "ValidateVolumeCapabilities: volume ID is missing")
@@ -147,11 +188,15 @@ func (c *ControllerServer) ValidateVolumeCapabilities(_ context.Context, req *cs
 	if req.GetVolumeCapabilities() == nil {
 		return nil, status.Error(codes.InvalidArgument, "ValidateVolumeCapabilities: volume capabilities is missing")
 	}
+	if acquired := c.volumeLocks.TryAcquire(req.GetVolumeId()); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, req.GetVolumeId())
+	}
+	defer c.volumeLocks.Release(req.GetVolumeId())
 
 	bucketName, _ := volumeIDToBucketPrefix(req.GetVolumeId())
 
 	// create s3 client and check if bucket exists
-	client, err := utils.NewClientFromSecrets(req.GetSecrets())
+	client, err := c.newClient(ctx, req.GetSecrets())
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "ValidateVolumeCapabilities: failed to initialize s3 client: %s", err.Error())
 	}
@@ -163,24 +208,29 @@ func (c *ControllerServer) ValidateVolumeCapabilities(_ context.Context, req *cs
 		return nil, status.Errorf(codes.NotFound, "ValidateVolumeCapabilities: bucket %s does not exist", bucketName)
 	}
 
-	supportedAccessModes := &csi.VolumeCapability_AccessMode{
-		Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
-	}
 	for _, capability := range req.GetVolumeCapabilities() {
-		if capability.GetAccessMode().Mode != supportedAccessModes.GetMode() {
+		if !slices.Contains(supportedAccessModes, capability.GetAccessMode().GetMode()) {
 			return &csi.ValidateVolumeCapabilitiesResponse{
-				Message: "Only single node writer is supported",
+				Message: fmt.Sprintf("access mode %s is not supported", capability.GetAccessMode().GetMode()),
+			}, nil
+		}
+		if err := mounter.ValidateCapability(ctx, req.GetVolumeContext()["mounter"], capability); err != nil {
+			return &csi.ValidateVolumeCapabilitiesResponse{
+				Message: err.Error(),
+			}, nil
+		}
+		if _, _, err := parseMountFlags(req.GetVolumeContext()["mounter"], capability.GetMount().GetMountFlags()); err != nil {
+			return &csi.ValidateVolumeCapabilitiesResponse{
+				Message: err.Error(),
 			}, nil
 		}
 	}
 
 	return &csi.ValidateVolumeCapabilitiesResponse{
 		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
-			VolumeCapabilities: []*csi.VolumeCapability{
-				{
-					AccessMode: supportedAccessModes,
-				},
-			},
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+			Parameters:         req.GetParameters(),
 		},
 	}, nil
 }
@@ -217,7 +267,9 @@ func (c *ControllerServer) ControllerExpandVolume(context.Context, *csi.Controll
 
 // ControllerGetCapabilities implements csi.ControllerServer.
 func (c *ControllerServer) ControllerGetCapabilities(context.Context, *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "ControllerGetCapabilities: not implemented")
+	return &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: c.capabilities,
+	}, nil
 }
 
 // ControllerGetVolume implements csi.ControllerServer.
@@ -240,6 +292,16 @@ func (c *ControllerServer) ControllerUnpublishVolume(context.Context, *csi.Contr
 	return nil, status.Error(codes.Unimplemented, "ControllerUnpublishVolume: not implemented")
 }
 
+// newClient returns an S3 client of the endpoint in the secrets and records the endpoint for the health checks.
+func (c *ControllerServer) newClient(ctx context.Context, secrets map[string]string) (*utils.S3Client, error) {
+	client, err := utils.NewClientFromSecrets(ctx, secrets)
+	if err != nil {
+		return nil, err
+	}
+	c.endpoints.Add(secrets["endpoint"])
+	return client, nil
+}
+
 // volumeIDToBucketPrefix returns the bucket name and prefix based on the volumeID.
 // Prefix is empty if volumeID does not have a slash in the name.
 func volumeIDToBucketPrefix(volumeID string) (string, string) {
@@ -1,10 +1,21 @@
 package driver
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	common "github.com/kubernetes-csi/drivers/pkg/csi-common"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 /**
@@ -52,3 +63,140 @@ func TestNewControllerServiceCapability(t *testing.T) {
 		})
 	}
 }
+
+func TestControllerVolumeLocks(t *testing.T) {
+	c := newTestControllerServer()
+	c.volumeLocks.TryAcquire("bucket/pvc-1")
+	defer c.volumeLocks.Release("bucket/pvc-1")
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "Test CreateVolume in a bucket",
+			call: func() error {
+				_, err := c.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+					Name:               "pvc-1",
+					Parameters:         map[string]string{"bucket": "bucket"},
+					VolumeCapabilities: []*csi.VolumeCapability{{}},
+				})
+				return err
+			},
+		},
+		{
+			name: "Test DeleteVolume",
+			call: func() error {
+				_, err := c.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "bucket/pvc-1"})
+				return err
+			},
+		},
+		{
+			name: "Test ValidateVolumeCapabilities",
+			call: func() error {
+				_, err := c.ValidateVolumeCapabilities(context.Background(), &csi.ValidateVolumeCapabilitiesRequest{
+					VolumeId:           "bucket/pvc-1",
+					VolumeCapabilities: []*csi.VolumeCapability{{}},
+				})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); status.Code(err) != codes.Aborted {
+				t.Errorf("error = %v, want code %v", err, codes.Aborted)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeLocksBucketVolumeID(t *testing.T) {
+	// the backend holds the first request of CreateVolume until DeleteVolume was called
+	requested, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	backend := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		once.Do(func() {
+			close(requested)
+			<-release
+		})
+	}))
+	defer backend.Close()
+	secrets := map[string]string{"endpoint": backend.URL, "region": "us-east-1", "accessKeyID": "key", "secretAccessKey": "secret"}
+	c := newTestControllerServer()
+
+	created := make(chan error, 1)
+	go func() {
+		_, err := c.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:       "pvc-1",
+			Parameters: map[string]string{"bucket": "bucket"},
+			VolumeCapabilities: []*csi.VolumeCapability{{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+			}},
+			Secrets: secrets,
+		})
+		created <- err
+	}()
+	<-requested
+
+	deleted := make(chan error, 1)
+	go func() {
+		_, err := c.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "bucket/pvc-1", Secrets: secrets})
+		deleted <- err
+	}()
+	select {
+	case err := <-deleted:
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("DeleteVolume() during CreateVolume error = %v, want code %v", err, codes.Aborted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("DeleteVolume() during CreateVolume did not return %v", codes.Aborted)
+	}
+	close(release)
+	if err := <-created; err != nil {
+		t.Errorf("CreateVolume() error = %v", err)
+	}
+	// the probes of the controller check the endpoints of the requests
+	if got := c.endpoints.List(); !reflect.DeepEqual(got, []string{backend.URL}) {
+		t.Errorf("CreateVolume() recorded endpoints %v, want %v", got, []string{backend.URL})
+	}
+}
+
+// newTestControllerServer returns a controller server which can create and delete volumes
+func newTestControllerServer() *ControllerServer {
+	d := common.NewCSIDriver(DefaultDriverName, "test", "controller-1")
+	d.AddControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME})
+	return NewControllerServer(&Driver{Driver: d, VolumeLocks: utils.NewVolumeLocks(), usedEndpoints: &endpointSet{}})
+}
+
+func TestCreateVolumeMountFlags(t *testing.T) {
+	defer mounter.SetOptionPolicy(nil, nil)
+	mounter.SetOptionPolicy(nil, []string{"kernel_cache"})
+	c := newTestControllerServer()
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		mountFlags []string
+	}{
+		{name: "Test unknown s3fs flag", mountFlags: []string{"url=http://attacker"}},
+		{name: "Test flag denied by the administrator", mountFlags: []string{"nonempty", "kernel_cache"}},
+		{name: "Test unknown mountpoint-s3 flag", params: map[string]string{"mounter": "mountpoint-s3"}, mountFlags: []string{"endpoint_url=http://attacker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:       "pvc-1",
+				Parameters: tt.params,
+				VolumeCapabilities: []*csi.VolumeCapability{{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{MountFlags: tt.mountFlags}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+				}},
+			})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("CreateVolume() error = %v, want code %v", err, codes.InvalidArgument)
+			}
+		})
+	}
+}
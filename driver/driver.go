@@ -1,13 +1,28 @@
 package driver
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/keington/s3-csi-driver/driver/pkg"
 	"github.com/keington/s3-csi-driver/driver/utils"
+	"github.com/keington/s3-csi-driver/driver/utils/metrics"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+	"github.com/keington/s3-csi-driver/driver/utils/mounthelper"
+	"github.com/keington/s3-csi-driver/driver/utils/tlsconfig"
+	"github.com/keington/s3-csi-driver/driver/utils/tracing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	common "github.com/kubernetes-csi/drivers/pkg/csi-common"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 	"sigs.k8s.io/cloud-provider-azure/pkg/cache"
@@ -35,6 +50,32 @@ const (
 	MountOptionsField = "mountoptions"
 	// MountPermissionsField is the field name for mount permissions.
 	MountPermissionsField = "mountpermissions"
+	// ParamUID is the owner uid of the files in the volume.
+	ParamUID = "uid"
+	// ParamGID is the owner gid of the files in the volume.
+	ParamGID = "gid"
+	// ParamUmask is the octal umask applied to the files in the volume.
+	ParamUmask = "umask"
+	// ParamVFSCacheMode is the rclone vfs cache mode of the volume.
+	ParamVFSCacheMode = "vfsCacheMode"
+	// ParamCacheDir is the name of the volume cache directory under the node cache root.
+	ParamCacheDir = "cacheDir"
+	// ParamCacheSize is the size of the local data cache of the volume, setting it enables the cache.
+	ParamCacheSize = "cacheSize"
+	// ParamAllowDelete allows mountpoint-s3 to delete objects.
+	ParamAllowDelete = "allowDelete"
+	// ParamAllowOverwrite allows mountpoint-s3 to overwrite existing objects.
+	ParamAllowOverwrite = "allowOverwrite"
+	// ParamSubPath is the directory of the volume to publish, relative to the root of the volume.
+	ParamSubPath = "subPath"
+	// ParamSubPathCreate allows creating a missing subPath directory when publishing.
+	ParamSubPathCreate = "subPathCreate"
+	// ParamBucket is the bucket of an ephemeral inline volume.
+	ParamBucket = "bucket"
+	// ParamPrefix is the prefix in the bucket of an ephemeral inline volume.
+	ParamPrefix = "prefix"
+	// EphemeralKey is set to "true" in the volume context of ephemeral inline volumes.
+	EphemeralKey = "csi.storage.k8s.io/ephemeral"
 	// PvcNameKey is the key for PVC name.
 	PvcNameKey = "csi.storage.k8s.io/pvc/name"
 	// PvcNamespaceKey is the key for PVC namespace.
@@ -49,14 +90,72 @@ const (
 	PvNameMetadata = "${pv.metadata.name}"
 )
 
+// Mode decides which CSI services the driver serves.
+type Mode string
+
+const (
+	// ModeController serves the controller service only, it needs neither /dev/fuse nor mount privileges.
+	ModeController Mode = "controller"
+	// ModeNode serves the node service only.
+	ModeNode Mode = "node"
+	// ModeAll serves both the controller and the node service.
+	ModeAll Mode = "all"
+)
+
+// servesController reports whether the controller service is served in the mode.
+func (m Mode) servesController() bool {
+	return m == ModeController || m == ModeAll
+}
+
+// servesNode reports whether the node service is served in the mode.
+func (m Mode) servesNode() bool {
+	return m == ModeNode || m == ModeAll
+}
+
+// supportedAccessModes are the volume access modes supported by the driver.
+var supportedAccessModes = []csi.VolumeCapability_AccessMode_Mode{
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+	csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+	csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER,
+	csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+}
+
 // Driver represents the CSI driver.
 type Driver struct {
 	Name                            string                             // Name is the name of the driver.
+	Mode                            Mode                               // Mode decides which CSI services are served.
 	NodeID                          string                             // NodeID is the ID of the node where the driver is running.
 	Version                         string                             // Version is the version of the driver.
 	EndPoint                        string                             // EndPoint is the endpoint of the driver.
+	TLS                             tlsconfig.Options                  // TLS are the credentials of a TCP endpoint.
+	InsecureTCP                     bool                               // InsecureTCP allows a TCP endpoint without TLS.
 	MountPermissions                uint64                             // MountPermissions is the mount permissions for the driver.
 	WorkingMountDir                 string                             // WorkingMountDir is the working directory for mount operations.
+	RuntimeDir                      string                             // RuntimeDir is the driver-owned directory of the per-mount credential files.
+	FuseRestartPolicy               string                             // FuseRestartPolicy decides whether exited FUSE processes are restarted.
+	FuseMaxRestarts                 int                                // FuseMaxRestarts limits the restarts of a FUSE process.
+	MountHelperEndpoint             string                             // MountHelperEndpoint is the socket of the mount helper running the FUSE processes.
+	EnableEphemeral                 bool                               // EnableEphemeral allows ephemeral inline volumes.
+	CacheRoot                       string                             // CacheRoot is the node directory holding the volume caches.
+	CacheBudget                     int64                              // CacheBudget is the total size of the volume caches in bytes.
+	DefaultMounter                  string                             // DefaultMounter is the mounter of the volumes which select none.
+	MountOptionsAllow               []string                           // MountOptionsAllow are the only mount options volumes may set, if not empty.
+	MountOptionsDeny                []string                           // MountOptionsDeny are the mount options volumes must not set.
+	MountTimeouts                   map[string]time.Duration           // MountTimeouts override the mount timeouts of the mounters.
+	DeleteParallelism               int                                // DeleteParallelism is the number of objects removed at once when they are deleted one by one.
+	MetricsAddress                  string                             // MetricsAddress is the address of the metrics endpoint.
+	OTLPEndpoint                    string                             // OTLPEndpoint is the collector the traces are exported to.
+	OTLPInsecure                    bool                               // OTLPInsecure disables TLS towards the collector.
+	TraceSampleRatio                float64                            // TraceSampleRatio is the share of the sampled traces.
+	ShutdownTimeout                 time.Duration                      // ShutdownTimeout is how long the in-flight calls are drained on shutdown.
+	RequiredMounters                []string                           // RequiredMounters are the mounters whose binaries the node must have to be healthy.
+	ProbeEndpoints                  []string                           // ProbeEndpoints are the S3 endpoints the controller must reach to be healthy.
+	usedEndpoints                   *endpointSet                       // usedEndpoints are the S3 endpoints of the controller requests, checked without ProbeEndpoints.
+	caches                          *cacheManager                      // caches manages the local data caches of the volumes.
+	mu                              sync.RWMutex                       // mu guards options and the settings reload replaces while the driver runs.
+	options                         DriverOptions                      // options are the options the driver runs with, the reloaded ones included.
+	config                          *ConfigLoader                      // config reloads the options from the config file, nil without one.
 	DefaultOnDeletePolicy           string                             // DefaultOnDeletePolicy is the default policy for handling volumes on delete.
 	NodeServer                      *NodeServer                        // NodeServer is the server for handling node service requests.
 	ControllerServer                *ControllerServer                  // ControllerServer is the server for handling controller service requests.
@@ -70,86 +169,425 @@ type Driver struct {
 }
 
 // DriverOptions represents the options for creating a new driver.
+// They are given by the flags and by the config file, in which they are named by their json tags.
 type DriverOptions struct {
-	DriverName                      string // DriverName is the name of the CSI driver.
-	NodeID                          string // NodeID is the unique identifier of the node where the driver is running.
-	EndPoint                        string // EndPoint is the CSI endpoint address.
-	MountPermissions                uint64 // MountPermissions is the permission mode for mounting volumes.
-	WorkingMountDir                 string // WorkingMountDir is the directory where volumes are mounted.
-	VolumeStatsCacheExpireInMinutes int    // VolumeStatsCacheExpireInMinutes is the expiration time for volume statistics cache in minutes.
+	DriverName                      string          `json:"driverName,omitempty"`                      // DriverName is the name of the CSI driver.
+	Mode                            string          `json:"mode,omitempty"`                            // Mode is controller, node or all, empty means all.
+	NodeID                          string          `json:"nodeID,omitempty"`                          // NodeID is the unique identifier of the node where the driver is running.
+	EndPoint                        string          `json:"endpoint,omitempty"`                        // EndPoint is the CSI endpoint address.
+	TLSCertFile                     string          `json:"tlsCertFile,omitempty"`                     // TLSCertFile is the PEM certificate chain served on a TCP endpoint.
+	TLSKeyFile                      string          `json:"tlsKeyFile,omitempty"`                      // TLSKeyFile is the PEM private key of the TLS certificate.
+	TLSClientCAFile                 string          `json:"tlsClientCAFile,omitempty"`                 // TLSClientCAFile are the PEM CA certificates the client certificates are verified with, empty accepts any client.
+	InsecureTCP                     bool            `json:"insecureTCP,omitempty"`                     // InsecureTCP allows a TCP endpoint without TLS, which anyone reaching it can call.
+	MountPermissions                uint64          `json:"mountPermissions,omitempty"`                // MountPermissions is the permission mode for mounting volumes.
+	WorkingMountDir                 string          `json:"workingMountDir,omitempty"`                 // WorkingMountDir is the directory where volumes are mounted.
+	RuntimeDir                      string          `json:"runtimeDir,omitempty"`                      // RuntimeDir is the driver-owned directory of the per-mount credential files.
+	FuseRestartPolicy               string          `json:"fuseRestartPolicy,omitempty"`               // FuseRestartPolicy decides whether exited FUSE processes are restarted: Never, OnFailure or Always.
+	FuseMaxRestarts                 int             `json:"fuseMaxRestarts,omitempty"`                 // FuseMaxRestarts limits the restarts of a FUSE process, 0 means unlimited.
+	MountHelperEndpoint             string          `json:"mountHelperEndpoint,omitempty"`             // MountHelperEndpoint is the socket of the mount helper, empty runs the FUSE processes in the driver.
+	EnableEphemeral                 bool            `json:"enableEphemeral,omitempty"`                 // EnableEphemeral allows ephemeral inline volumes, the Ephemeral lifecycle mode of the CSIDriver.
+	CacheRoot                       string          `json:"cacheRoot,omitempty"`                       // CacheRoot is the node directory holding the volume caches, empty disables caching.
+	CacheBudget                     string          `json:"cacheBudget,omitempty"`                     // CacheBudget is the total size of the volume caches as a quantity, 0 means unlimited.
+	DefaultMounter                  string          `json:"defaultMounter,omitempty"`                  // DefaultMounter is the mounter of the volumes which select none, empty means s3fs.
+	MountOptionsAllow               []string        `json:"mountOptionsAllow,omitempty"`               // MountOptionsAllow are the only mount options volumes may set, as name or mounter:name; empty allows all known options.
+	MountOptionsDeny                []string        `json:"mountOptionsDeny,omitempty"`                // MountOptionsDeny are the mount options volumes must not set, as name or mounter:name.
+	MountTimeouts                   string          `json:"mountTimeouts,omitempty"`                   // MountTimeouts are the mount timeouts as duration or mounter=duration, comma separated.
+	DeleteParallelism               int             `json:"deleteParallelism,omitempty"`               // DeleteParallelism is the number of objects removed at once when they are deleted one by one, 0 means the default.
+	MetricsAddress                  string          `json:"metricsAddress,omitempty"`                  // MetricsAddress is the address of the Prometheus metrics endpoint, empty disables it.
+	OTLPEndpoint                    string          `json:"otlpEndpoint,omitempty"`                    // OTLPEndpoint is the host:port of the OTLP gRPC collector, empty disables tracing.
+	OTLPInsecure                    bool            `json:"otlpInsecure,omitempty"`                    // OTLPInsecure connects to the OTLP collector without TLS.
+	TraceSampleRatio                float64         `json:"traceSampleRatio,omitempty"`                // TraceSampleRatio is the share of the traces which are sampled, between 0 and 1.
+	ShutdownTimeout                 metav1.Duration `json:"shutdownTimeout,omitempty"`                 // ShutdownTimeout is how long the in-flight calls are drained on SIGTERM before they checkpoint.
+	RequiredMounters                []string        `json:"requiredMounters,omitempty"`                // RequiredMounters are the mounters whose binaries are checked by the probes of the node, empty means the default mounter.
+	ProbeEndpoints                  []string        `json:"probeEndpoints,omitempty"`                  // ProbeEndpoints are the S3 endpoint URLs whose reachability is checked by the probes of the controller, empty checks the endpoints of the requests.
+	VolumeStatsCacheExpireInMinutes int             `json:"volumeStatsCacheExpireInMinutes,omitempty"` // VolumeStatsCacheExpireInMinutes is the expiration time for volume statistics cache in minutes.
 }
 
 // NewDriver creates a new driver object.
 func NewDriver(options *DriverOptions) (*Driver, error) {
 	klog.Infof("driver: %v version: %v", options.DriverName, pkg.DriverVersion)
 
+	driver, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	driver.Driver = common.NewCSIDriver(options.DriverName, pkg.DriverVersion, driver.NodeID)
+	if driver.Driver == nil {
+		return nil, fmt.Errorf("invalid driver name %q or version %q", options.DriverName, pkg.DriverVersion)
+	}
+	driver.VolumeLocks = utils.NewVolumeLocks()
+	driver.usedEndpoints = &endpointSet{}
+	return driver, nil
+}
+
+// parseOptions validates the options and returns the driver they configure, without the CSI driver.
+func parseOptions(options *DriverOptions) (*Driver, error) {
+	mode := Mode(options.Mode)
+	switch mode {
+	case "":
+		mode = ModeAll
+	case ModeController, ModeNode, ModeAll:
+	default:
+		return nil, fmt.Errorf("invalid mode %q, must be %s, %s or %s", options.Mode, ModeController, ModeNode, ModeAll)
+	}
+	nodeID := options.NodeID
+	if nodeID == "" {
+		if mode.servesNode() {
+			return nil, fmt.Errorf("node ID is required in %s mode", mode)
+		}
+		// the controller is not bound to a node, its host name identifies it
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the host name as node ID: %v", err)
+		}
+		nodeID = hostname
+	}
+
+	var cacheBudget int64
+	if options.CacheBudget != "" {
+		budget, err := resource.ParseQuantity(options.CacheBudget)
+		if err != nil || budget.Sign() < 0 {
+			return nil, fmt.Errorf("invalid cache budget %q", options.CacheBudget)
+		}
+		cacheBudget = budget.Value()
+	}
+
+	mountTimeouts, err := mounter.ParseMountTimeouts(options.MountTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateEndpointTLS(options); err != nil {
+		return nil, err
+	}
+
+	if options.TraceSampleRatio < 0 || options.TraceSampleRatio > 1 {
+		return nil, fmt.Errorf("invalid trace sample ratio %v, must be between 0 and 1", options.TraceSampleRatio)
+	}
+
+	if options.ShutdownTimeout.Duration < 0 {
+		return nil, fmt.Errorf("invalid shutdown timeout %v", options.ShutdownTimeout.Duration)
+	}
+
+	defaultMounter := options.DefaultMounter
+	if defaultMounter == "" {
+		defaultMounter = mounter.DefaultMounter
+	} else if _, ok := mounter.Lookup(defaultMounter); !ok {
+		return nil, fmt.Errorf("invalid default mounter %q, must be one of %s", defaultMounter, strings.Join(mounter.Backends(), ", "))
+	}
+
+	deleteParallelism := options.DeleteParallelism
+	switch {
+	case deleteParallelism < 0:
+		return nil, fmt.Errorf("invalid delete parallelism %d", deleteParallelism)
+	case deleteParallelism == 0:
+		deleteParallelism = utils.DefaultDeleteParallelism
+	}
+
+	requiredMounters := options.RequiredMounters
+	if len(requiredMounters) == 0 {
+		requiredMounters = []string{defaultMounter}
+	}
+	for _, name := range requiredMounters {
+		if _, ok := mounter.Lookup(name); !ok {
+			return nil, fmt.Errorf("invalid required mounter %q, must be one of %s", name, strings.Join(mounter.Backends(), ", "))
+		}
+	}
+	for _, endpoint := range options.ProbeEndpoints {
+		if _, err := endpointAddress(endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	switch mounter.RestartPolicy(options.FuseRestartPolicy) {
+	case "", mounter.RestartNever, mounter.RestartOnFailure, mounter.RestartAlways:
+	default:
+		return nil, fmt.Errorf("invalid FUSE restart policy %q", options.FuseRestartPolicy)
+	}
+
 	driver := &Driver{
 		Name:                            options.DriverName,
+		Mode:                            mode,
 		Version:                         pkg.DriverVersion,
-		NodeID:                          options.NodeID,
+		NodeID:                          nodeID,
+		EndPoint:                        options.EndPoint,
+		TLS:                             tlsconfig.Options{CertFile: options.TLSCertFile, KeyFile: options.TLSKeyFile, ClientCAFile: options.TLSClientCAFile},
+		InsecureTCP:                     options.InsecureTCP,
 		MountPermissions:                options.MountPermissions,
 		WorkingMountDir:                 options.WorkingMountDir,
+		RuntimeDir:                      options.RuntimeDir,
+		FuseRestartPolicy:               options.FuseRestartPolicy,
+		FuseMaxRestarts:                 options.FuseMaxRestarts,
+		MountHelperEndpoint:             options.MountHelperEndpoint,
+		EnableEphemeral:                 options.EnableEphemeral,
+		CacheRoot:                       options.CacheRoot,
+		CacheBudget:                     cacheBudget,
+		DefaultMounter:                  defaultMounter,
+		MountOptionsAllow:               options.MountOptionsAllow,
+		MountOptionsDeny:                options.MountOptionsDeny,
+		MountTimeouts:                   mountTimeouts,
+		DeleteParallelism:               deleteParallelism,
+		MetricsAddress:                  options.MetricsAddress,
+		OTLPEndpoint:                    options.OTLPEndpoint,
+		OTLPInsecure:                    options.OTLPInsecure,
+		TraceSampleRatio:                options.TraceSampleRatio,
+		ShutdownTimeout:                 options.ShutdownTimeout.Duration,
+		RequiredMounters:                requiredMounters,
+		ProbeEndpoints:                  options.ProbeEndpoints,
 		VolumeStatsCacheExpireInMinutes: options.VolumeStatsCacheExpireInMinutes,
+		options:                         *options,
 	}
 
 	return driver, nil
 }
 
 // NewControllerServer creates a new controller server.
-func NewControllerServer(d *common.CSIDriver) *ControllerServer {
+func NewControllerServer(d *Driver) *ControllerServer {
 	return &ControllerServer{
-		DefaultControllerServer: common.NewDefaultControllerServer(d),
+		DefaultControllerServer: common.NewDefaultControllerServer(d.Driver),
+		volumeLocks:             d.VolumeLocks,
+		capabilities:            d.ControllerServiceCapability,
+		endpoints:               d.usedEndpoints,
 	}
 }
 
 // NewNodeServer creates a new node server.
-func NewNodeServer(d *common.CSIDriver) *NodeServer {
+func NewNodeServer(d *Driver) *NodeServer {
 	return &NodeServer{
-		DefaultNodeServer: common.NewDefaultNodeServer(d),
+		DefaultNodeServer:    common.NewDefaultNodeServer(d.Driver),
+		nodeID:               d.NodeID,
+		mountPermissions:     d.MountPermissions,
+		volumeLocks:          d.VolumeLocks,
+		enableEphemeral:      d.EnableEphemeral,
+		caches:               d.caches,
+		stagedMounts:         make(map[string]stagedMount),
+		ephemeralMounts:      make(map[string]mounter.Mounter),
+		newMounter:           mounter.NewMounter,
+		mounterForMountPoint: mounter.ForMountPoint,
+		mountUtils:           mount.New(""),
 	}
 }
 
 // NewIdentityServer creates a new identity server.
-func NewIdentityServer(d *common.CSIDriver) *IdentityServer {
+func NewIdentityServer(d *Driver) *IdentityServer {
 	return &IdentityServer{
-		DefaultIdentityServer: common.NewDefaultIdentityServer(d),
+		DefaultIdentityServer: common.NewDefaultIdentityServer(d.Driver),
+		controller:            d.Mode.servesController(),
+		health:                newHealthChecker(d),
 	}
 }
 
-// Run starts the driver in the specified mode.
-func (d *Driver) Run(mode bool) {
+// Run starts the driver in its mode and blocks until the gRPC server exits.
+func (d *Driver) Run() {
 	versionMeta, err := pkg.GetVersionYaml(d.Name)
 	if err != nil {
 		klog.Fatalf("Failed to get version info: %v", err)
 	}
 	klog.Infof("Driver infomation meta: %s", versionMeta)
+	klog.Infof("Running in %s mode", d.Mode)
 
-	// Create a new CSI driver.
-	d.AddControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{
-		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
-	})
-	d.Driver.AddVolumeCapabilityAccessModes([]csi.VolumeCapability_AccessMode_Mode{
-		csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+	// The controller only talks to S3, the FUSE mounts are set up by the node service alone.
+	if d.Mode.servesNode() {
+		d.setupNode()
+	}
+	d.applySettings()
+
+	// Expose the metrics of the calls, mounts and S3 operations for alerting.
+	if d.MetricsAddress != "" {
+		if d.Mode.servesNode() {
+			metrics.Registry.MustRegister(metrics.NewActiveMountsCollector(mounter.ActiveMounts))
+		}
+		if d.caches != nil {
+			metrics.Registry.MustRegister(metrics.NewCacheCollector(d.caches.TotalUsage))
+		}
+		if err := metrics.Serve(d.MetricsAddress); err != nil {
+			klog.Fatalf("Failed to serve metrics on %s: %v", d.MetricsAddress, err)
+		}
+	}
+
+	// Trace the calls down to the S3 requests and mount steps, spans are dropped without a collector.
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Endpoint:    d.OTLPEndpoint,
+		Insecure:    d.OTLPInsecure,
+		SampleRatio: d.TraceSampleRatio,
+		ServiceName: d.Name,
 	})
+	if err != nil {
+		klog.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			klog.Warningf("Failed to flush traces: %v", err)
+		}
+	}()
+
+	d.Driver.AddVolumeCapabilityAccessModes(supportedAccessModes)
+
+	// Create the gRPC servers of the mode.
+	d.IdentityServer = NewIdentityServer(d)
+	if d.Mode.servesController() {
+		d.AddControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{
+			csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		})
+		d.ControllerServer = NewControllerServer(d)
+	}
+	if d.Mode.servesNode() {
+		d.NodeServer = NewNodeServer(d)
+	}
+
+	// Start the gRPC servers, a nil server is not registered.
+	var cs csi.ControllerServer
+	if d.ControllerServer != nil {
+		cs = d.ControllerServer
+	}
+	var ns csi.NodeServer
+	if d.NodeServer != nil {
+		ns = d.NodeServer
+	}
+	s := NewNonBlockingGRPCServerOptions(d.NodeID)
+	if d.TLS.CertFile != "" {
+		// the credentials are reloaded when the certificates are rotated
+		config, err := tlsconfig.NewServerConfig(d.TLS)
+		if err != nil {
+			klog.Fatalf("Failed to set up TLS: %v", err)
+		}
+		s.SetTLSConfig(config)
+	} else if d.InsecureTCP {
+		klog.Warningf("Serving %s without TLS, anyone reaching it can call the driver", d.EndPoint)
+	}
+	s.Start(d.EndPoint, d.IdentityServer, cs, ns)
 
-	// Create gRPC servers.
-	d.ControllerServer = NewControllerServer(d.Driver)
-	d.NodeServer = NewNodeServer(d.Driver)
-	d.IdentityServer = NewIdentityServer(d.Driver)
+	// Apply the changes of the config file which are safe while the driver runs.
+	if d.config != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go d.watchConfig(ctx, configPollInterval)
+	}
 
-	// Start the gRPC servers.
-	s := NewNonBlockingGRPCServerOptions()
-	s.Start(d.EndPoint, d.IdentityServer, d.ControllerServer, d.NodeServer, mode)
+	// Drain the in-flight calls on SIGTERM, deletions and mounts still running at the
+	// shutdown timeout stop at their next checkpoint and are resumed by a retry.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		sig := <-signals
+		klog.Infof("Received %v, stopping driver", sig)
+		s.Shutdown(d.ShutdownTimeout, utils.BeginShutdown)
+	}()
 	s.Wait()
+	klog.Infof("Driver stopped")
+}
+
+// validateEndpointTLS returns an error if a TCP endpoint would be served without TLS
+// unless explicitly allowed, or the TLS options are incomplete.
+func validateEndpointTLS(options *DriverOptions) error {
+	tlsSet := options.TLSCertFile != "" || options.TLSKeyFile != "" || options.TLSClientCAFile != ""
+	if tlsSet && (options.TLSCertFile == "" || options.TLSKeyFile == "") {
+		return fmt.Errorf("both a TLS certificate and key file are required")
+	}
+	if options.EndPoint == "" {
+		return nil
+	}
+	proto, _, err := utils.ParseEndpoint(options.EndPoint)
+	if err != nil {
+		return err
+	}
+	switch {
+	case proto != "tcp" && tlsSet:
+		return fmt.Errorf("TLS is only served on tcp endpoints, not on %s", options.EndPoint)
+	case proto == "tcp" && !tlsSet && !options.InsecureTCP:
+		return fmt.Errorf("refusing to serve %s without TLS, set a TLS certificate and key or allow it with insecure TCP", options.EndPoint)
+	}
+	return nil
+}
+
+// setupNode prepares the FUSE mounts of the node service.
+func (d *Driver) setupNode() {
+	// Keep the credentials of every mount in its own file and drop the ones left behind by
+	// mounts which disappeared while the driver was not running.
+	if d.RuntimeDir != "" {
+		mounter.SetRuntimeDir(d.RuntimeDir)
+	}
+	ctx := context.Background()
+	if err := mounter.GarbageCollectCredentials(ctx); err != nil {
+		klog.Warningf("Failed to garbage collect credential files: %v", err)
+	}
+
+	// Volumes with a cacheSize get a cache directory under the cache root, within the cache budget.
+	if d.CacheRoot != "" {
+		caches, err := newCacheManager(d.CacheRoot, d.CacheBudget)
+		if err != nil {
+			klog.Fatalf("Failed to initialize the volume caches: %v", err)
+		}
+		mounted, err := mountedPaths()
+		if err != nil {
+			klog.Warningf("Failed to list mounts, keeping all volume caches: %v", err)
+		} else if err := caches.GarbageCollect(ctx, mounted); err != nil {
+			klog.Warningf("Failed to garbage collect volume caches: %v", err)
+		}
+		go caches.run(ctx, cacheSweepInterval)
+		d.caches = caches
+	}
+
+	// With a mount helper the FUSE processes survive restarts of the driver, its mounts are adopted.
+	if d.MountHelperEndpoint != "" {
+		client, err := mounthelper.Dial(d.MountHelperEndpoint)
+		if err != nil {
+			klog.Fatalf("Failed to connect to mount helper: %v", err)
+		}
+		mounter.SetRunner(client)
+		processes, err := mounter.ListProcesses(context.Background())
+		if err != nil {
+			klog.Warningf("Mount helper %s is not reachable yet: %v", d.MountHelperEndpoint, err)
+		}
+		for _, p := range processes {
+			klog.Infof("Adopting mount %s served by %s process %d of the mount helper", p.Target, p.Command, p.Pid)
+		}
+	}
+}
+
+// applySettings applies the settings which can change while the driver runs, at start and
+// when they are reloaded from the config file.
+func (d *Driver) applySettings() {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	// Only the mount options of the schema of the mounter, restricted by the administrator, are accepted.
+	mounter.SetOptionPolicy(d.MountOptionsAllow, d.MountOptionsDeny)
+	mounter.SetDefaultMounter(d.DefaultMounter)
+	utils.SetDeleteParallelism(d.DeleteParallelism)
+
+	if d.Mode.servesNode() {
+		mounter.SetMountTimeouts(d.MountTimeouts)
+
+		// FUSE processes run in the foreground under supervision, with their output in per-mount logs.
+		supervisorOptions := mounter.DefaultSupervisorOptions()
+		supervisorOptions.LogDir = filepath.Join(d.WorkingMountDir, "fuse-logs")
+		if d.FuseRestartPolicy != "" {
+			supervisorOptions.RestartPolicy = mounter.RestartPolicy(d.FuseRestartPolicy)
+		}
+		supervisorOptions.MaxRestarts = d.FuseMaxRestarts
+		mounter.ConfigureSupervisor(supervisorOptions)
+
+		if d.caches != nil {
+			d.caches.SetBudget(d.CacheBudget)
+		}
+	}
+
+	if d.IdentityServer != nil {
+		d.IdentityServer.health.SetChecks(healthChecks(d))
+	}
 }
 
 // AddControllerServiceCapabilities adds the given controller service capabilities to the driver.
+// The requests of the controller service are validated against them.
 func (d *Driver) AddControllerServiceCapabilities(cl []csi.ControllerServiceCapability_RPC_Type) {
 	var csc []*csi.ControllerServiceCapability
 	for _, c := range cl {
 		csc = append(csc, NewControllerServiceCapability(c))
 	}
 	d.ControllerServiceCapability = csc
+	d.Driver.AddControllerServiceCapabilities(cl)
 }
 
 // IsCorruptDir checks if the directory is corrupt.
@@ -0,0 +1,170 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 00:48:31
+ * @file: driver_test.go
+ * @description: driver 单测
+ */
+
+func TestNewDriverMode(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		mode       string
+		nodeID     string
+		wantMode   Mode
+		wantNodeID string
+		wantErr    bool
+	}{
+		{name: "Test default mode", nodeID: "node-1", wantMode: ModeAll, wantNodeID: "node-1"},
+		{name: "Test controller without node ID", mode: "controller", wantMode: ModeController, wantNodeID: hostname},
+		{name: "Test node", mode: "node", nodeID: "node-1", wantMode: ModeNode, wantNodeID: "node-1"},
+		{name: "Test node without node ID", mode: "node", wantErr: true},
+		{name: "Test all without node ID", mode: "all", wantErr: true},
+		{name: "Test unknown mode", mode: "monolith", nodeID: "node-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDriver(&DriverOptions{DriverName: DefaultDriverName, Mode: tt.mode, NodeID: tt.nodeID})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if d.Mode != tt.wantMode || d.NodeID != tt.wantNodeID {
+				t.Errorf("NewDriver() mode %s, node ID %s, want %s, %s", d.Mode, d.NodeID, tt.wantMode, tt.wantNodeID)
+			}
+			if d.Driver == nil {
+				t.Errorf("NewDriver() did not create the CSI driver")
+			}
+		})
+	}
+}
+
+func TestNewDriverRequiredMounters(t *testing.T) {
+	tests := []struct {
+		name             string
+		defaultMounter   string
+		requiredMounters []string
+		want             []string
+		wantErr          bool
+	}{
+		{name: "Test default", want: []string{"s3fs"}},
+		{name: "Test default mounter", defaultMounter: "geesefs", want: []string{"geesefs"}},
+		{name: "Test required mounters", defaultMounter: "geesefs", requiredMounters: []string{"s3fs", "goofys"}, want: []string{"s3fs", "goofys"}},
+		{name: "Test unknown mounter", requiredMounters: []string{"s3ql"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDriver(&DriverOptions{DriverName: DefaultDriverName, NodeID: "node-1", DefaultMounter: tt.defaultMounter, RequiredMounters: tt.requiredMounters})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(d.RequiredMounters, tt.want) {
+				t.Errorf("NewDriver() required mounters %v, want %v", d.RequiredMounters, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPluginCapabilities(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           Mode
+		wantController bool
+	}{
+		{name: "Test controller", mode: ModeController, wantController: true},
+		{name: "Test node", mode: ModeNode, wantController: false},
+		{name: "Test all", mode: ModeAll, wantController: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDriver(&DriverOptions{DriverName: DefaultDriverName, Mode: string(tt.mode), NodeID: "node-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := NewIdentityServer(d).GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+			if err != nil {
+				t.Fatalf("GetPluginCapabilities() error = %v", err)
+			}
+			controller := false
+			for _, c := range resp.GetCapabilities() {
+				controller = controller || c.GetService().GetType() == csi.PluginCapability_Service_CONTROLLER_SERVICE
+			}
+			if controller != tt.wantController {
+				t.Errorf("GetPluginCapabilities() controller service = %v, want %v", controller, tt.wantController)
+			}
+		})
+	}
+}
+
+func TestServeMode(t *testing.T) {
+	d, err := NewDriver(&DriverOptions{DriverName: DefaultDriverName, Mode: string(ModeNode), NodeID: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "csi.sock")
+	n, _ := newTestNodeServer()
+	s := NewNonBlockingGRPCServerOptions(d.NodeID)
+	s.Start("unix:/"+socket, NewIdentityServer(d), nil, n)
+	defer s.Wait()
+	defer s.ForceStop()
+
+	conn, err := grpc.Dial("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	if _, err := csi.NewNodeClient(conn).NodeGetCapabilities(ctx, &csi.NodeGetCapabilitiesRequest{}); err != nil {
+		t.Errorf("NodeGetCapabilities() error = %v", err)
+	}
+	// the controller service is not registered in node mode
+	_, err = csi.NewControllerClient(conn).ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("ControllerGetCapabilities() error = %v, want %v", err, codes.Unimplemented)
+	}
+}
+
+func TestValidateEndpointTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		options DriverOptions
+		wantErr bool
+	}{
+		{name: "Test unix", options: DriverOptions{EndPoint: "unix://tmp/csi.sock"}},
+		{name: "Test tcp with TLS", options: DriverOptions{EndPoint: "tcp://0.0.0.0:10000", TLSCertFile: "tls.crt", TLSKeyFile: "tls.key", TLSClientCAFile: "ca.crt"}},
+		{name: "Test insecure tcp", options: DriverOptions{EndPoint: "tcp://0.0.0.0:10000", InsecureTCP: true}},
+		{name: "Test tcp without TLS", options: DriverOptions{EndPoint: "tcp://0.0.0.0:10000"}, wantErr: true},
+		{name: "Test certificate without key", options: DriverOptions{EndPoint: "tcp://0.0.0.0:10000", TLSCertFile: "tls.crt"}, wantErr: true},
+		{name: "Test client CA without certificate", options: DriverOptions{EndPoint: "tcp://0.0.0.0:10000", TLSClientCAFile: "ca.crt", InsecureTCP: true}, wantErr: true},
+		{name: "Test TLS on unix", options: DriverOptions{EndPoint: "unix://tmp/csi.sock", TLSCertFile: "tls.crt", TLSKeyFile: "tls.key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateEndpointTLS(&tt.options); (err != nil) != tt.wantErr {
+				t.Errorf("validateEndpointTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
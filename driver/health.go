@@ -0,0 +1,247 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 01:36:20
+ * @file: health.go
+ * @description: 健康检查，按运行模式检查 FUSE 设备、挂载器与后端连通性，结果缓存
+ */
+
+var (
+	// fuseDevicePath is the FUSE device the mounters open, it is replaced in tests.
+	fuseDevicePath = "/dev/fuse"
+	// probeCacheTTL is how long the result of the health checks is reused by Probe.
+	probeCacheTTL = 10 * time.Second
+	// probeTimeout bounds the health checks of a probe.
+	probeTimeout = 3 * time.Second
+)
+
+// errNotReady is returned by the checks which cannot tell the health of the driver yet
+var errNotReady = errors.New("not ready")
+
+// healthCheck is a named check of a dependency of the driver
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthChecker runs the health checks of the mode of the driver and caches the result,
+// so that frequent probes neither load the node nor the backends.
+type healthChecker struct {
+	checks []healthCheck
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// Check returns an error describing every failed check, nil if the driver is healthy.
+func (h *healthChecker) Check(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.checkedAt.IsZero() && time.Since(h.checkedAt) < probeCacheTTL {
+		return h.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	var failures checkErrors
+	for _, c := range h.checks {
+		if err := c.check(ctx); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	h.err = nil
+	if len(failures) > 0 {
+		h.err = failures
+	}
+	h.checkedAt = time.Now()
+	return h.err
+}
+
+// checkErrors are the failed health checks
+type checkErrors []error
+
+func (e checkErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e checkErrors) Unwrap() []error {
+	return e
+}
+
+// isNotReady reports whether every failed check only says that the driver is not ready yet.
+func isNotReady(err error) bool {
+	var failures checkErrors
+	if !errors.As(err, &failures) {
+		return errors.Is(err, errNotReady)
+	}
+	for _, failure := range failures {
+		if !errors.Is(failure, errNotReady) {
+			return false
+		}
+	}
+	return true
+}
+
+// endpointSet are the S3 endpoints the controller was called with
+type endpointSet struct {
+	mu        sync.Mutex
+	endpoints []string
+}
+
+// Add records an endpoint, a nil set records nothing.
+func (s *endpointSet) Add(endpoint string) {
+	if s == nil || endpoint == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.endpoints, endpoint) {
+		s.endpoints = append(s.endpoints, endpoint)
+	}
+}
+
+// List returns the recorded endpoints.
+func (s *endpointSet) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.endpoints)
+}
+
+// SetChecks replaces the health checks, the next probe runs them.
+func (h *healthChecker) SetChecks(checks []healthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = checks
+	h.checkedAt = time.Time{}
+}
+
+// newHealthChecker returns a health checker running the health checks of the driver.
+func newHealthChecker(d *Driver) *healthChecker {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return &healthChecker{checks: healthChecks(d)}
+}
+
+// healthChecks returns the health checks of the mode of the driver, the caller holds d.mu.
+func healthChecks(d *Driver) []healthCheck {
+	var checks []healthCheck
+	if d.Mode.servesNode() {
+		if d.MountHelperEndpoint != "" {
+			// the FUSE processes run in the mount helper, which has the device and the binaries
+			checks = append(checks, healthCheck{name: "mount helper " + d.MountHelperEndpoint, check: func(ctx context.Context) error {
+				_, err := mounter.ListProcesses(ctx)
+				return err
+			}})
+		} else {
+			checks = append(checks, healthCheck{name: "FUSE device", check: checkFuseDevice})
+			for _, name := range d.RequiredMounters {
+				if backend, ok := mounter.Lookup(name); ok {
+					checks = append(checks, healthCheck{name: "mounter " + name, check: func(context.Context) error {
+						return backend.CheckAvailable()
+					}})
+				}
+			}
+		}
+	}
+	if d.Mode.servesController() {
+		for _, endpoint := range d.ProbeEndpoints {
+			checks = append(checks, healthCheck{name: "endpoint " + endpoint, check: func(ctx context.Context) error {
+				return checkEndpoint(ctx, endpoint)
+			}})
+		}
+		if len(d.ProbeEndpoints) == 0 {
+			// the endpoints come with the secrets of the requests, those used so far are checked
+			checks = append(checks, healthCheck{name: "endpoints", check: func(ctx context.Context) error {
+				return checkUsedEndpoints(ctx, d.usedEndpoints)
+			}})
+		}
+	}
+	return checks
+}
+
+// checkFuseDevice returns an error if the FUSE device is missing.
+func checkFuseDevice(context.Context) error {
+	info, err := os.Stat(fuseDevicePath)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("%s is not a character device", fuseDevicePath)
+	}
+	return nil
+}
+
+// checkUsedEndpoints returns an error naming the used endpoints which are unreachable,
+// errNotReady if no probe endpoint is configured and no request used one yet.
+func checkUsedEndpoints(ctx context.Context, used *endpointSet) error {
+	endpoints := used.List()
+	if len(endpoints) == 0 {
+		return fmt.Errorf("no S3 endpoint is configured in probe endpoints nor used by a request: %w", errNotReady)
+	}
+	var failures []string
+	for _, endpoint := range endpoints {
+		if err := checkEndpoint(ctx, endpoint); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", endpoint, err))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// checkEndpoint returns an error if no TCP connection can be opened to the S3 endpoint.
+func checkEndpoint(ctx context.Context, endpoint string) error {
+	address, err := endpointAddress(endpoint)
+	if err != nil {
+		return err
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// endpointAddress returns the host:port of an S3 endpoint URL like the endpoint of the secrets,
+// the port defaults to the one of the scheme.
+func endpointAddress(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %v", endpoint, err)
+	}
+	port := u.Port()
+	switch {
+	case u.Hostname() == "":
+		return "", fmt.Errorf("invalid endpoint %q: must be a URL like https://s3.example.com", endpoint)
+	case port != "":
+	case u.Scheme == "https":
+		port = "443"
+	case u.Scheme == "http":
+		port = "80"
+	default:
+		return "", fmt.Errorf("invalid endpoint %q: scheme must be http or https", endpoint)
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
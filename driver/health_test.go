@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 01:42:10
+ * @file: health_test.go
+ * @description: health 单测
+ */
+
+func TestEndpointAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Test https", endpoint: "https://s3.example.com", want: "s3.example.com:443"},
+		{name: "Test http", endpoint: "http://minio.storage", want: "minio.storage:80"},
+		{name: "Test port", endpoint: "http://10.0.0.1:9000", want: "10.0.0.1:9000"},
+		{name: "Test no scheme", endpoint: "s3.example.com", wantErr: true},
+		{name: "Test unknown scheme", endpoint: "ftp://s3.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := endpointAddress(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("endpointAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("endpointAddress() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	oldFuseDevicePath := fuseDevicePath
+	defer func() { fuseDevicePath = oldFuseDevicePath }()
+	// a regular file is not a FUSE device
+	regularFile := filepath.Join(t.TempDir(), "fuse")
+	if err := os.WriteFile(regularFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	reachable := "http://" + listener.Addr().String()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "http://" + closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		mode       Mode
+		fuseDevice string
+		endpoints  []string
+		used       []string
+		notReady   bool
+		wantErr    string
+	}{
+		{name: "Test controller reachable", mode: ModeController, endpoints: []string{reachable}},
+		{name: "Test controller unreachable", mode: ModeController, endpoints: []string{reachable, unreachable}, wantErr: "endpoint " + unreachable},
+		{name: "Test controller without endpoints", mode: ModeController, notReady: true},
+		{name: "Test controller used endpoint reachable", mode: ModeController, used: []string{reachable}},
+		{name: "Test controller used endpoint unreachable", mode: ModeController, used: []string{reachable, unreachable}, wantErr: unreachable},
+		{name: "Test controller ignores used endpoints", mode: ModeController, endpoints: []string{reachable}, used: []string{unreachable}},
+		{name: "Test node without FUSE device", mode: ModeNode, fuseDevice: filepath.Join(t.TempDir(), "missing"), wantErr: "FUSE device"},
+		{name: "Test node with regular file", mode: ModeNode, fuseDevice: regularFile, wantErr: "not a character device"},
+		{name: "Test node ignores endpoints", mode: ModeNode, fuseDevice: regularFile, endpoints: []string{unreachable}, wantErr: "FUSE device"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fuseDevicePath = tt.fuseDevice
+			d, err := NewDriver(&DriverOptions{DriverName: DefaultDriverName, Mode: string(tt.mode), NodeID: "node-1", ProbeEndpoints: tt.endpoints})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, endpoint := range tt.used {
+				d.usedEndpoints.Add(endpoint)
+			}
+			resp, err := NewIdentityServer(d).Probe(context.Background(), &csi.ProbeRequest{})
+			if tt.notReady {
+				if err != nil || resp.GetReady() == nil || resp.GetReady().GetValue() {
+					t.Errorf("Probe() = %v, %v, want not ready", resp, err)
+				}
+				return
+			}
+			if tt.wantErr == "" {
+				if err != nil || !resp.GetReady().GetValue() {
+					t.Errorf("Probe() = %v, %v, want ready", resp, err)
+				}
+				return
+			}
+			if status.Code(err) != codes.FailedPrecondition || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Probe() error = %v, want %v containing %q", err, codes.FailedPrecondition, tt.wantErr)
+			}
+			if strings.Contains(err.Error(), "endpoint") && tt.mode == ModeNode {
+				t.Errorf("Probe() error = %v, checks the endpoints in node mode", err)
+			}
+		})
+	}
+}
+
+func TestHealthCheckerCache(t *testing.T) {
+	oldProbeCacheTTL := probeCacheTTL
+	probeCacheTTL = 100 * time.Millisecond
+	defer func() { probeCacheTTL = oldProbeCacheTTL }()
+
+	calls := 0
+	h := &healthChecker{checks: []healthCheck{{name: "backend", check: func(context.Context) error {
+		calls++
+		return errors.New("unreachable")
+	}}}}
+	for i := 0; i < 3; i++ {
+		if err := h.Check(context.Background()); err == nil || err.Error() != "backend: unreachable" {
+			t.Errorf("Check() error = %v, want backend: unreachable", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("check ran %d times, want the cached result", calls)
+	}
+
+	time.Sleep(probeCacheTTL)
+	h.Check(context.Background())
+	if calls != 2 {
+		t.Errorf("check ran %d times, want it to run again once the result expired", calls)
+	}
+}
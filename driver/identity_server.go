@@ -3,6 +3,9 @@ package driver
 import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
 	pb "google.golang.org/protobuf/types/known/wrapperspb"
 	common "github.com/kubernetes-csi/drivers/pkg/csi-common"
 )
@@ -16,11 +19,28 @@ import (
 
 type IdentityServer struct {
 	*common.DefaultIdentityServer
+	// controller is set if the controller service is served
+	controller bool
+	// health checks the dependencies of the served services
+	health *healthChecker
 }
 
 // Probe detect whether the plugin is running.
-// 探测检查插件是否正在运行, 此方法不需要返回任何内容, 而且截至目前，规范也没有规定该返回什么, so, 返回nil
-func (i *IdentityServer) Probe(_ context.Context, _ *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+// 探测插件是否可用: 按运行模式检查 FUSE 设备、挂载器与后端连通性, 不可用时返回 FailedPrecondition, 由 livenessprobe 重启插件
+// 尚无可检查的 S3 endpoint 时返回未就绪
+func (i *IdentityServer) Probe(ctx context.Context, _ *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	if i.health != nil {
+		if err := i.health.Check(ctx); isNotReady(err) {
+			klog.FromContext(ctx).V(4).Info("Plugin is not ready", "reason", err)
+			return &csi.ProbeResponse{
+				Ready: &pb.BoolValue{
+					Value: false,
+				},
+			}, nil
+		} else if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Probe: plugin is not healthy: %v", err)
+		}
+	}
 	return &csi.ProbeResponse{
 		Ready: &pb.BoolValue{
 			Value: true,
@@ -29,16 +49,19 @@ func (i *IdentityServer) Probe(_ context.Context, _ *csi.ProbeRequest) (*csi.Pro
 }
 
 // GetPluginCapabilities returns the capabilities of the plugin.
+// The controller service is only advertised by the modes serving it.
 func (i *IdentityServer) GetPluginCapabilities(_ context.Context, _ *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
-	return &csi.GetPluginCapabilitiesResponse{
-		Capabilities: []*csi.PluginCapability{
-			{
-				Type: &csi.PluginCapability_Service_{
-					Service: &csi.PluginCapability_Service{
-						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
-					},
+	capabilities := []*csi.PluginCapability{}
+	if i.controller {
+		capabilities = append(capabilities, &csi.PluginCapability{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
 				},
 			},
-		},
+		})
+	}
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: capabilities,
 	}, nil
 }
@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"path/filepath"
+
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 21:38:05
+ * @file: mountinfo.go
+ * @description: 从 mountinfo 重建暂存挂载的绑定挂载引用
+ */
+
+// procMountInfoPath is the mount table of the driver, it is replaced in tests.
+var procMountInfoPath = "/proc/self/mountinfo"
+
+// mountRefs returns the other mount points of the filesystem mounted at path, in the order
+// they were mounted. Bind mounts share the device of their source, and every FUSE mount has
+// a device of its own, so these are the staging mount and the bind mounts of a volume.
+// It returns nil if nothing is mounted at path.
+func mountRefs(path string) ([]string, error) {
+	infos, err := mount.ParseMountInfo(procMountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	var device *mount.MountInfo
+	for i := range infos {
+		if infos[i].MountPoint == path {
+			device = &infos[i]
+		}
+	}
+	if device == nil {
+		return nil, nil
+	}
+
+	var refs []string
+	for _, info := range infos {
+		if info.MountPoint != path && info.Major == device.Major && info.Minor == device.Minor {
+			refs = append(refs, info.MountPoint)
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keington/s3-csi-driver/driver/utils/testutil"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 21:44:19
+ * @file: mountinfo_test.go
+ * @description: mountinfo 单测
+ */
+
+func TestMountRefs(t *testing.T) {
+	testutil.FakeMountInfo(t, &procMountInfoPath,
+		testutil.Mount{MountPoint: "/staging/a", Device: "0:51"},
+		testutil.Mount{MountPoint: "/staging/b", Device: "0:52"},
+		testutil.Mount{MountPoint: "/pods/1/mount", Device: "0:51"},
+		testutil.Mount{MountPoint: "/pods/2/mount", Device: "0:52"},
+		testutil.Mount{MountPoint: "/pods/3/mount", Device: "0:51"},
+	)
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{
+			name: "Test staging path",
+			path: "/staging/a",
+			want: []string{"/pods/1/mount", "/pods/3/mount"},
+		},
+		{
+			name: "Test bind mount",
+			path: "/pods/2/mount/",
+			want: []string{"/staging/b"},
+		},
+		{
+			name: "Test not mounted",
+			path: "/pods/4/mount",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mountRefs(tt.path)
+			if err != nil {
+				t.Fatalf("mountRefs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mountRefs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
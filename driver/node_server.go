@@ -4,17 +4,23 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/keington/s3-csi-driver/driver/utils"
 	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+	"github.com/keington/s3-csi-driver/driver/utils/tracing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	common "github.com/kubernetes-csi/drivers/pkg/csi-common"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 	mount "k8s.io/mount-utils"
 )
@@ -28,33 +34,112 @@ import (
 
 type NodeServer struct {
 	*common.DefaultNodeServer
+	// nodeID identifies the node to the controller and the scheduler.
+	nodeID string
+	// mountPermissions is the default permission mode of the mount points.
+	mountPermissions uint64
+	// enableEphemeral allows ephemeral inline volumes.
+	enableEphemeral bool
+	// stagedMounts records the options each staging path was mounted with,
+	// so that later publishes of the same staged volume can be checked for conflicts.
+	stagedMounts map[string]stagedMount
+	// ephemeralMounts holds the mounters of the ephemeral volumes mounted at their target paths.
+	ephemeralMounts map[string]mounter.Mounter
+	mu              sync.Mutex
+	// volumeLocks serializes the operations on a volume.
+	volumeLocks *utils.VolumeLocks
+	// caches manages the local data caches of the volumes, nil if caching is disabled.
+	caches *cacheManager
+	// newMounter creates the mounter of a volume, it is replaced in tests.
+	newMounter func(meta *utils.Metadata, cfg *utils.Config) (mounter.Mounter, error)
+	// mounterForMountPoint returns the mounter of a mount the driver did not create itself.
+	mounterForMountPoint func(target string) mounter.Mounter
+	// mountUtils bind mounts the volumes and checks mount points, it is replaced by a mount.FakeMounter in tests.
+	mountUtils mount.Interface
 }
 
+// stagedMount holds the options a staged FUSE mount was created with.
+type stagedMount struct {
+	// fuseFlags are the mount flags of the capability as arguments of the mounter
+	fuseFlags  []string
+	mountGroup string
+	// mounter serves the mount, nil if the mount was adopted after a restart.
+	mounter mounter.Mounter
+}
+
+// mountFlagRegexp matches a single mount flag, optionally with a value.
+var mountFlagRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]*(=[^\s,"'\\]*)?$`)
+
 // NodeGetInfo implements csi.NodeServer.
-// Returns the supported capabilities of the node server.
+// Returns the ID of the node the volumes are published on.
 func (n *NodeServer) NodeGetInfo(_ context.Context, _ *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeGetInfo is not implemented")
+	return &csi.NodeGetInfoResponse{
+		NodeId: n.nodeID,
+	}, nil
 }
 
 // NodeGetCapabilities implements csi.NodeServer.
 // Returns the supported capabilities of the node server.
 func (n *NodeServer) NodeGetCapabilities(_ context.Context, _ *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
-	nodeServerCapabilities := &csi.NodeServiceCapability{
-		Type: &csi.NodeServiceCapability_Rpc{
-			Rpc: &csi.NodeServiceCapability_RPC{
-				Type: csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+	nodeServerCapabilities := make([]*csi.NodeServiceCapability, 0, 3)
+	for _, c := range []csi.NodeServiceCapability_RPC_Type{
+		csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+		csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP,
+		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+	} {
+		nodeServerCapabilities = append(nodeServerCapabilities, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: c,
+				},
 			},
-		},
+		})
 	}
 
 	return &csi.NodeGetCapabilitiesResponse{
-		Capabilities: []*csi.NodeServiceCapability{nodeServerCapabilities},
+		Capabilities: nodeServerCapabilities,
 	}, nil
 }
 
 // NodeGetVolumeStats implements csi.NodeServer.
+// A bucket has no capacity, the usage of the local cache of the volume is reported instead.
 func (n *NodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeGetVolumeStats is not implemented")
+	volumePath := req.GetVolumePath()
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodeGetVolumeStats: Volume ID missing in request")
+	}
+	if len(volumePath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodeGetVolumeStats: Volume path missing in request")
+	}
+	// Stats only read the volume and kubelet polls them, so they do not wait for the operations
+	// on the volume. A path unmounted meanwhile is reported as not found.
+	if _, err := os.Stat(volumePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "NodeGetVolumeStats: volume path %s does not exist", volumePath)
+		}
+		return nil, status.Errorf(codes.Internal, "NodeGetVolumeStats: failed to stat %s: %v", volumePath, err)
+	}
+
+	usage := []*csi.VolumeUsage{}
+	if n.caches != nil {
+		// the cache belongs to the staged mount, or to the target of an ephemeral volume
+		for _, target := range []string{req.GetStagingTargetPath(), volumePath} {
+			used, size, ok, err := n.caches.Usage(target)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "NodeGetVolumeStats: failed to get cache usage of %s: %v", target, err)
+			}
+			if ok {
+				usage = append(usage, &csi.VolumeUsage{
+					Unit:      csi.VolumeUsage_BYTES,
+					Total:     size,
+					Used:      used,
+					Available: max(size-used, 0),
+				})
+				break
+			}
+		}
+	}
+	return &csi.NodeGetVolumeStatsResponse{Usage: usage}, nil
 }
 
 // NodeExpandVolume implements csi.NodeServer.
@@ -64,7 +149,7 @@ func (n *NodeServer) NodeExpandVolume(_ context.Context, _ *csi.NodeExpandVolume
 
 // NodePublishVolume implements csi.NodeServer.
 // Mounts the volume.
-func (n *NodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+func (n *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
 	volumeId := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
 	stagingTargetPath := req.GetStagingTargetPath()
@@ -76,36 +161,70 @@ func (n *NodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishVo
 	if len(volumeId) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "NodePublishVolume: Volume ID missing in request")
 	}
-	if len(stagingTargetPath) == 0 {
-		return nil, status.Error(codes.InvalidArgument, "NodePublishVolume: Staging target path missing in request")
-	}
 	if len(targetPath) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "NodePublishVolume: Target path missing in request")
 	}
+	if acquired := n.volumeLocks.TryAcquire(volumeId); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, volumeId)
+	}
+	defer n.volumeLocks.Release(volumeId)
 
-	notMount, err := checkMount(stagingTargetPath)
+	mountFlags := req.GetVolumeCapability().GetMount().GetMountFlags()
+	flagsReadOnly, fuseFlags, err := parseMountFlags(req.GetVolumeContext()["mounter"], mountFlags)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume: %v", err)
 	}
-	if notMount {
-		// Staged mount is dead by some reason. Revive it
-		bucketName, prefix := volumeIDToBucketPrefix(volumeId)
-		s3, err := utils.NewClientFromSecrets(req.GetSecrets())
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize S3 client: %s", err)
+	readOnly := req.GetReadonly() || flagsReadOnly || isReadOnlyAccessMode(req.GetVolumeCapability().GetAccessMode().GetMode())
+	if _, err := getMeta("", "", req.GetVolumeContext(), n.mountPermissions); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume: %v", err)
+	}
+	if err := mounter.ValidateCapability(ctx, req.GetVolumeContext()["mounter"], req.GetVolumeCapability()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume: %v", err)
+	}
+	staged := stagedMount{
+		fuseFlags:  fuseFlags,
+		mountGroup: req.GetVolumeCapability().GetMount().GetVolumeMountGroup(),
+	}
+
+	if req.GetVolumeContext()[EphemeralKey] == "true" {
+		if req.GetVolumeContext()[ParamSubPath] != "" {
+			return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume: %s is not supported for ephemeral volumes, use %s", ParamSubPath, ParamPrefix)
 		}
-		meta := getMeta(bucketName, prefix, req.VolumeContext)
-		mounter, err := mounter.NewMounter(meta, s3.Config)
-		if err != nil {
+		if err := n.publishEphemeralVolume(ctx, volumeId, targetPath, staged, readOnly, req.GetVolumeContext(), req.GetSecrets()); err != nil {
 			return nil, err
 		}
-		if err := mounter.Mount(stagingTargetPath, volumeId); err != nil {
+		klog.FromContext(ctx).V(2).Info("Published ephemeral volume", "targetPath", targetPath)
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+	if len(stagingTargetPath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodePublishVolume: Staging target path missing in request")
+	}
+
+	notMount, err := n.checkMount(stagingTargetPath)
+	if err != nil && !mount.IsCorruptedMnt(err) {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err == nil && !notMount {
+		err = n.stagedMounter(stagingTargetPath).Check(stagingTargetPath)
+	}
+	if err != nil {
+		klog.FromContext(ctx).Info("Staged mount is not healthy, remounting", "stagingTargetPath", stagingTargetPath, "err", err)
+		if err := n.unstageVolume(ctx, stagingTargetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "NodePublishVolume: failed to clean up staged mount %s: %v", stagingTargetPath, err)
+		}
+		notMount = true
+	}
+	if notMount {
+		// Staged mount is dead by some reason. Revive it
+		if err := n.stageVolume(ctx, volumeId, stagingTargetPath, staged, req.GetVolumeContext(), req.GetSecrets()); err != nil {
 			return nil, err
 		}
+	} else if err := n.checkStagedMount(stagingTargetPath, staged); err != nil {
+		return nil, err
 	}
 
 	// check if the volume is already being published to the target path
-	notMount, err = checkMount(targetPath)
+	notMount, err = n.checkMount(targetPath)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -113,30 +232,42 @@ func (n *NodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishVo
 		return &csi.NodePublishVolumeResponse{}, nil
 	}
 
-	// TODO: Implement readOnly & mountFlags
-	readOnly := req.GetReadonly()
-	mountFlags := req.GetVolumeCapability().GetMount().GetMountFlags()
 	attrib := req.GetVolumeContext()
 
-	klog.V(2).Infof("NodePublishVolume: volumeID %s, targetPath %s, stagingTargetPath %s, readOnly %v, mountFlags %v, attributes %v",
-		volumeId, targetPath, stagingTargetPath, readOnly, mountFlags, attrib)
+	// publish a sub-directory of the staged mount, so that pods can share one volume
+	source := stagingTargetPath
+	if subPath := attrib[ParamSubPath]; subPath != "" {
+		meta, err := getMeta("", "", attrib, n.mountPermissions)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		create, _ := strconv.ParseBool(attrib[ParamSubPathCreate])
+		dir, err := openSubPath(stagingTargetPath, subPath, create && !readOnly, subPathMode(meta))
+		if err != nil {
+			return nil, err
+		}
+		defer dir.Close()
+		// bind mount the opened directory, a path would be resolved again and could be swapped for a link meanwhile
+		source = fdPath(dir)
+	}
 
-	cmd := exec.Command("mount", "--bind", stagingTargetPath, targetPath)
-	cmd.Stderr = os.Stderr
-	klog.V(4).Infof("s3: mounting volume %s to %s", volumeId, targetPath)
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "NodePublishVolume: failed to mount %s to %s: %v", stagingTargetPath, targetPath, out)
+	logger := klog.FromContext(ctx)
+	logger.V(2).Info("Publishing volume", "targetPath", targetPath, "stagingTargetPath", stagingTargetPath,
+		"readOnly", readOnly, "mountFlags", mountFlags, "attributes", attrib)
+
+	logger.V(4).Info("Bind mounting volume", "source", source, "targetPath", targetPath)
+	if err := n.bindMount(ctx, source, targetPath, readOnly); err != nil {
+		return nil, status.Errorf(codes.Internal, "NodePublishVolume: failed to mount %s to %s: %v", source, targetPath, err)
 	}
 
-	klog.V(2).Infof("NodePublishVolume: volume (%s) mounted to %s", volumeId, targetPath)
+	logger.V(2).Info("Published volume", "targetPath", targetPath)
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
 // NodeUnpublishVolume implements csi.NodeServer.
 // Unmounts the volume.
-func (n *NodeServer) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
 	volumeID := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
 
@@ -147,27 +278,328 @@ func (n *NodeServer) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpubli
 	if len(targetPath) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "NodeUnpublishVolume: Target path missing in request")
 	}
+	if acquired := n.volumeLocks.TryAcquire(volumeID); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, volumeID)
+	}
+	defer n.volumeLocks.Release(volumeID)
 
-	if err := mounter.Unmount(targetPath); err != nil {
+	n.mu.Lock()
+	m, ok := n.ephemeralMounts[targetPath]
+	n.mu.Unlock()
+	stagingTargetPath := ""
+	if !ok {
+		// A bind mount, or the FUSE mount of an ephemeral volume published before the driver restarted.
+		m = n.mounterForMountPoint(targetPath)
+		stagingTargetPath = n.findStagingPath(ctx, targetPath)
+	}
+	// Unmount treats a target which is not mounted as unmounted and detaches corrupted mounts lazily.
+	if err := m.Unmount(ctx, targetPath); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	klog.V(4).Infof("s3: volume %s has been unmounted.", volumeID)
+	if err := n.releaseCache(targetPath); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	n.mu.Lock()
+	delete(n.ephemeralMounts, targetPath)
+	n.mu.Unlock()
+	if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+		return nil, status.Errorf(codes.Internal, "NodeUnpublishVolume: failed to remove %s: %v", targetPath, err)
+	}
+	klog.FromContext(ctx).V(4).Info("Unpublished volume", "targetPath", targetPath)
+
+	if stagingTargetPath != "" {
+		if err := n.releaseStagedMount(ctx, stagingTargetPath, targetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "NodeUnpublishVolume: failed to unstage %s: %v", stagingTargetPath, err)
+		}
+	}
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
 // NodeStageVolume implements csi.NodeServer.
-func (n *NodeServer) NodeStageVolume(_ context.Context, _ *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeStageVolume is not implemented")
+// Mounts the bucket with the FUSE mounter at the staging path.
+func (n *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+	volumeId := req.GetVolumeId()
+	stagingTargetPath := req.GetStagingTargetPath()
+
+	// Check arguments
+	if req.GetVolumeCapability() == nil {
+		return nil, status.Error(codes.InvalidArgument, "NodeStageVolume: volume capability is missing")
+	}
+	if len(volumeId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodeStageVolume: Volume ID missing in request")
+	}
+	if len(stagingTargetPath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodeStageVolume: Staging target path missing in request")
+	}
+	if acquired := n.volumeLocks.TryAcquire(volumeId); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, volumeId)
+	}
+	defer n.volumeLocks.Release(volumeId)
+
+	_, fuseFlags, err := parseMountFlags(req.GetVolumeContext()["mounter"], req.GetVolumeCapability().GetMount().GetMountFlags())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodeStageVolume: %v", err)
+	}
+	if _, err := getMeta("", "", req.GetVolumeContext(), n.mountPermissions); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodeStageVolume: %v", err)
+	}
+	if err := mounter.ValidateCapability(ctx, req.GetVolumeContext()["mounter"], req.GetVolumeCapability()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodeStageVolume: %v", err)
+	}
+	staged := stagedMount{
+		fuseFlags:  fuseFlags,
+		mountGroup: req.GetVolumeCapability().GetMount().GetVolumeMountGroup(),
+	}
+
+	notMount, err := n.checkMount(stagingTargetPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !notMount {
+		if err := n.checkStagedMount(stagingTargetPath, staged); err != nil {
+			return nil, err
+		}
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
+	if err := n.stageVolume(ctx, volumeId, stagingTargetPath, staged, req.GetVolumeContext(), req.GetSecrets()); err != nil {
+		return nil, err
+	}
+	klog.FromContext(ctx).V(2).Info("Staged volume", "stagingTargetPath", stagingTargetPath)
+
+	return &csi.NodeStageVolumeResponse{}, nil
 }
 
 // NodeUnstageVolume implements csi.NodeServer.
-func (n *NodeServer) NodeUnstageVolume(_ context.Context, _ *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeUnstageVolume is not implemented")
+// Unmounts the FUSE mount at the staging path.
+func (n *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
+	volumeId := req.GetVolumeId()
+	stagingTargetPath := req.GetStagingTargetPath()
+
+	// Check arguments
+	if len(volumeId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodeUnstageVolume: Volume ID missing in request")
+	}
+	if len(stagingTargetPath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "NodeUnstageVolume: Staging target path missing in request")
+	}
+	if acquired := n.volumeLocks.TryAcquire(volumeId); !acquired {
+		return nil, status.Errorf(codes.Aborted, utils.VolumeOperationInProgressFmt, volumeId)
+	}
+	defer n.volumeLocks.Release(volumeId)
+
+	if err := n.unstageVolume(ctx, stagingTargetPath); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := os.Remove(stagingTargetPath); err != nil && !os.IsNotExist(err) {
+		return nil, status.Errorf(codes.Internal, "NodeUnstageVolume: failed to remove %s: %v", stagingTargetPath, err)
+	}
+	klog.FromContext(ctx).V(2).Info("Unstaged volume", "stagingTargetPath", stagingTargetPath)
+
+	return &csi.NodeUnstageVolumeResponse{}, nil
+}
+
+// stageVolume mounts the bucket of the volume at the staging path and
+// records the options it was mounted with.
+func (n *NodeServer) stageVolume(ctx context.Context, volumeId, stagingTargetPath string, staged stagedMount, volumeContext, secrets map[string]string) error {
+	bucketName, prefix := volumeIDToBucketPrefix(volumeId)
+	m, err := n.mountVolume(ctx, volumeId, stagingTargetPath, bucketName, prefix, staged, false, volumeContext, secrets)
+	if err != nil {
+		return err
+	}
+
+	staged.mounter = m
+	n.mu.Lock()
+	n.stagedMounts[stagingTargetPath] = staged
+	n.mu.Unlock()
+	return nil
+}
+
+// publishEphemeralVolume mounts the bucket named by the attributes of an ephemeral inline volume
+// directly at the target path, ephemeral volumes are not staged.
+func (n *NodeServer) publishEphemeralVolume(ctx context.Context, volumeId, targetPath string, staged stagedMount, readOnly bool, volumeContext, secrets map[string]string) error {
+	if !n.enableEphemeral {
+		return status.Error(codes.InvalidArgument, "NodePublishVolume: ephemeral inline volumes are not enabled")
+	}
+	bucketName := volumeContext[ParamBucket]
+	if bucketName == "" {
+		return status.Errorf(codes.InvalidArgument, "NodePublishVolume: volume attribute %s missing for ephemeral volume", ParamBucket)
+	}
+
+	notMount, err := n.checkMount(targetPath)
+	if err != nil && !mount.IsCorruptedMnt(err) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err == nil && !notMount {
+		return nil
+	}
+	if err != nil {
+		klog.FromContext(ctx).Info("Mount of ephemeral volume is corrupted, remounting", "targetPath", targetPath, "err", err)
+		if err := n.mounterForMountPoint(targetPath).Unmount(ctx, targetPath); err != nil {
+			return status.Errorf(codes.Internal, "NodePublishVolume: failed to clean up mount %s: %v", targetPath, err)
+		}
+	}
+
+	m, err := n.mountVolume(ctx, volumeId, targetPath, bucketName, volumeContext[ParamPrefix], staged, readOnly, volumeContext, secrets)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.ephemeralMounts[targetPath] = m
+	n.mu.Unlock()
+	return nil
+}
+
+// mountVolume mounts the bucket and prefix at target with the options of the volume context
+// and returns the mounter serving the mount. The cache of the volume is reserved first.
+func (n *NodeServer) mountVolume(ctx context.Context, volumeId, target, bucketName, prefix string, staged stagedMount, readOnly bool, volumeContext, secrets map[string]string) (_ mounter.Mounter, err error) {
+	ctx, span := tracing.Start(ctx, "mountVolume", attribute.String("volume_id", volumeId), attribute.String("target", target))
+	defer func() { tracing.End(span, err) }()
+	meta, err := getMeta(bucketName, prefix, volumeContext, n.mountPermissions)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	meta.MountFlags = staged.fuseFlags
+	meta.ReadOnly = readOnly
+	if staged.mountGroup != "" && meta.GID == nil {
+		// honor the fsGroup of the pod through the FUSE mount instead of a recursive chown
+		gid, err := parseID(staged.mountGroup)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid volume mount group %q: %v", staged.mountGroup, err)
+		}
+		meta.GID = &gid
+	}
+
+	s3, err := utils.NewClientFromSecrets(ctx, secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize S3 client: %s", err)
+	}
+	m, err := n.newMounter(meta, s3.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.CacheSize > 0 {
+		if n.caches == nil {
+			return nil, status.Error(codes.FailedPrecondition, "local volume caches are not enabled on this node")
+		}
+		backend, ok := mounter.Lookup(mounter.BackendName(meta, s3.Config))
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown mounter %s", mounter.BackendName(meta, s3.Config))
+		}
+		if meta.CacheDir, err = n.caches.Reserve(target, volumeId, volumeContext[ParamCacheDir], meta.CacheSize, !backend.LimitsCache); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Mount(ctx, target, volumeId); err != nil {
+		if releaseErr := n.releaseCache(target); releaseErr != nil {
+			klog.FromContext(ctx).Error(releaseErr, "Failed to release the cache", "target", target)
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// releaseCache deletes the cache of the volume mounted at target.
+func (n *NodeServer) releaseCache(target string) error {
+	if n.caches == nil {
+		return nil
+	}
+	return n.caches.Release(target)
+}
+
+// unstageVolume unmounts the FUSE mount at the staging path and stops its process.
+func (n *NodeServer) unstageVolume(ctx context.Context, stagingTargetPath string) error {
+	if err := n.stagedMounter(stagingTargetPath).Unmount(ctx, stagingTargetPath); err != nil {
+		return err
+	}
+	if err := n.releaseCache(stagingTargetPath); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	delete(n.stagedMounts, stagingTargetPath)
+	n.mu.Unlock()
+	return nil
+}
+
+// findStagingPath returns the staging path the target is bind mounted from, or "" if the
+// target is not a bind mount. Without a record of the staging path, e.g. after a restart of
+// the driver, the earliest mount of the filesystem is taken, which is the staging mount.
+func (n *NodeServer) findStagingPath(ctx context.Context, targetPath string) string {
+	refs, err := mountRefs(targetPath)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to look up the staged mount", "targetPath", targetPath, "err", err)
+		return ""
+	}
+	if len(refs) == 0 {
+		return ""
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ref := range refs {
+		if _, ok := n.stagedMounts[ref]; ok {
+			return ref
+		}
+	}
+	return refs[0]
+}
+
+// releaseStagedMount unstages the staging path once the unmounted target was its last bind mount.
+// The references are rebuilt from the mount table, so they survive restarts of the driver.
+func (n *NodeServer) releaseStagedMount(ctx context.Context, stagingTargetPath, targetPath string) error {
+	logger := klog.FromContext(ctx)
+	refs, err := mountRefs(stagingTargetPath)
+	if err != nil {
+		logger.Info("Failed to count the bind mounts, keeping the volume staged", "stagingTargetPath", stagingTargetPath, "err", err)
+		return nil
+	}
+	for _, ref := range refs {
+		// a lazily detached target may still be listed
+		if ref != filepath.Clean(targetPath) {
+			return nil
+		}
+	}
+	logger.V(2).Info("Unstaging volume, its last bind mount is gone", "stagingTargetPath", stagingTargetPath, "targetPath", targetPath)
+	return n.unstageVolume(ctx, stagingTargetPath)
+}
+
+// stagedMounter returns the mounter serving the staging path.
+func (n *NodeServer) stagedMounter(stagingTargetPath string) mounter.Mounter {
+	n.mu.Lock()
+	staged, ok := n.stagedMounts[stagingTargetPath]
+	n.mu.Unlock()
+	if ok && staged.mounter != nil {
+		return staged.mounter
+	}
+	return n.mounterForMountPoint(stagingTargetPath)
+}
+
+// checkStagedMount rejects options that differ from the ones the staging
+// path is already mounted with, since all publishes share the same FUSE mount.
+func (n *NodeServer) checkStagedMount(stagingTargetPath string, staged stagedMount) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	existing, ok := n.stagedMounts[stagingTargetPath]
+	if !ok {
+		// The staging path was mounted before the driver restarted, adopt the requested options.
+		n.stagedMounts[stagingTargetPath] = staged
+		return nil
+	}
+	if !slices.Equal(existing.fuseFlags, staged.fuseFlags) {
+		return status.Errorf(codes.FailedPrecondition,
+			"volume is already staged at %s with mount flags %v, which conflict with the requested mount flags %v", stagingTargetPath, existing.fuseFlags, staged.fuseFlags)
+	}
+	if existing.mountGroup != staged.mountGroup {
+		return status.Errorf(codes.FailedPrecondition,
+			"volume is already staged at %s with volume mount group %q, which conflicts with the requested group %q", stagingTargetPath, existing.mountGroup, staged.mountGroup)
+	}
+	return nil
 }
 
 // getMeta returns the metadata for the given bucket and prefix.
-func getMeta(bucketName, prefix string, context map[string]string) *utils.Metadata {
+func getMeta(bucketName, prefix string, context map[string]string, mountPermissions uint64) (*utils.Metadata, error) {
 	mountOptions := make([]string, 0)
 	mountOptStr := context["options"]
 	if mountOptStr != "" {
@@ -182,19 +614,104 @@ func getMeta(bucketName, prefix string, context map[string]string) *utils.Metada
 			mountOptions = append(mountOptions, string(opt))
 		}
 	}
+	// the options are passed to the FUSE process, only known and permitted ones are accepted
+	mountOptions, err := mounter.ParseOptions(context["mounter"], mountOptions)
+	if err != nil {
+		return nil, err
+	}
 	capacity, _ := strconv.ParseInt(context["capacity"], 10, 64)
-	return &utils.Metadata{
-		BucketName:    bucketName,
-		Prefix:        prefix,
-		Mounter:       context["mounter"],
-		MountOptions:  mountOptions,
-		CapacityBytes: capacity,
+	meta := &utils.Metadata{
+		BucketName:       bucketName,
+		Prefix:           prefix,
+		Mounter:          context["mounter"],
+		MountOptions:     mountOptions,
+		CapacityBytes:    capacity,
+		MountPermissions: mountPermissions,
+		VFSCacheMode:     context[ParamVFSCacheMode],
+	}
+
+	if v := context[MountPermissionsField]; v != "" {
+		perm, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || perm > 0777 {
+			return nil, fmt.Errorf("invalid %s %q: must be an octal mode up to 0777", MountPermissionsField, v)
+		}
+		meta.MountPermissions = perm
+	}
+	if v := context[ParamUID]; v != "" {
+		uid, err := parseID(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", ParamUID, v, err)
+		}
+		meta.UID = &uid
+	}
+	if v := context[ParamGID]; v != "" {
+		gid, err := parseID(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", ParamGID, v, err)
+		}
+		meta.GID = &gid
+	}
+	if v := context[ParamUmask]; v != "" {
+		umask, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || umask > 0777 {
+			return nil, fmt.Errorf("invalid %s %q: must be an octal mask up to 0777", ParamUmask, v)
+		}
+		mask := uint32(umask)
+		meta.Umask = &mask
+	}
+	if v := context[ParamCacheSize]; v != "" {
+		size, err := resource.ParseQuantity(v)
+		if err != nil || size.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid %s %q: must be a positive quantity", ParamCacheSize, v)
+		}
+		meta.CacheSize = size.Value()
+	}
+	if v := context[ParamCacheDir]; v != "" {
+		if meta.CacheSize == 0 {
+			return nil, fmt.Errorf("%s requires %s", ParamCacheDir, ParamCacheSize)
+		}
+		if err := validateCacheDir(v); err != nil {
+			return nil, err
+		}
+	}
+	for key, field := range map[string]*bool{
+		ParamAllowDelete:    &meta.AllowDelete,
+		ParamAllowOverwrite: &meta.AllowOverwrite,
+	} {
+		if v := context[key]; v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %v", key, v, err)
+			}
+			*field = b
+		}
 	}
+	if v := context[ParamSubPath]; v != "" {
+		if _, err := cleanSubPath(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := context[ParamSubPathCreate]; v != "" {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", ParamSubPathCreate, v, err)
+		}
+	}
+
+	return meta, nil
+}
+
+// parseID parses a numeric uid or gid.
+func parseID(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(id), nil
 }
 
-// checkMount checks if the target path is mounted.
-func checkMount(targetPath string) (bool, error) {
-	notMnt, err := mount.New("").IsLikelyNotMountPoint(targetPath)
+// checkMount checks if the target path is mounted, creating it if it does not exist.
+func (n *NodeServer) checkMount(targetPath string) (bool, error) {
+	notMnt, err := n.mountUtils.IsLikelyNotMountPoint(targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			if err = os.MkdirAll(targetPath, 0750); err != nil {
@@ -207,3 +724,64 @@ func checkMount(targetPath string) (bool, error) {
 	}
 	return notMnt, nil
 }
+
+// parseMountFlags validates the mount flags of a volume capability. The flags
+// that only apply to the bind mount are reported through readOnly, all others
+// must be options of the mounter permitted by the administrator and are returned
+// sorted, as arguments of the mounter.
+func parseMountFlags(mounterType string, mountFlags []string) (bool, []string, error) {
+	readOnly := false
+	fuseFlags := make([]string, 0, len(mountFlags))
+	for _, mountFlag := range mountFlags {
+		for _, flag := range strings.Split(mountFlag, ",") {
+			flag = strings.TrimSpace(flag)
+			if flag == "" {
+				continue
+			}
+			if !mountFlagRegexp.MatchString(flag) {
+				return false, nil, fmt.Errorf("invalid mount flag %q", flag)
+			}
+			switch flag {
+			case "ro":
+				readOnly = true
+			case "rw":
+			default:
+				fuseFlags = append(fuseFlags, flag)
+			}
+		}
+	}
+	slices.Sort(fuseFlags)
+	args, err := mounter.ParseMountFlags(mounterType, slices.Compact(fuseFlags))
+	if err != nil {
+		return false, nil, err
+	}
+	return readOnly, args, nil
+}
+
+// isReadOnlyAccessMode returns true if the access mode only allows reading the volume.
+func isReadOnlyAccessMode(mode csi.VolumeCapability_AccessMode_Mode) bool {
+	return mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY ||
+		mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY
+}
+
+// bindMount bind mounts the source to the target. The bind mount inherits the propagation
+// of the target directory, which kubelet sets up. Read-only bind mounts are remounted
+// read-only by mount-utils, since older kernels ignore ro on the initial bind.
+func (n *NodeServer) bindMount(ctx context.Context, source, target string, readOnly bool) (err error) {
+	_, span := tracing.Start(ctx, "bindMount", attribute.String("source", source), attribute.String("target", target))
+	defer func() { tracing.End(span, err) }()
+	options := []string{"bind"}
+	if readOnly {
+		options = append(options, "ro")
+	}
+	if err = n.mountUtils.Mount(source, target, "", options); err != nil {
+		// never leave a writable bind mount behind for a read-only publish
+		if notMount, checkErr := n.mountUtils.IsLikelyNotMountPoint(target); checkErr == nil && !notMount {
+			if unmountErr := n.mountUtils.Unmount(target); unmountErr != nil {
+				klog.FromContext(ctx).Error(unmountErr, "Failed to unmount after failed bind mount", "target", target)
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,693 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+	"github.com/keington/s3-csi-driver/driver/utils/testutil"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 10:12:31
+ * @file: node_server_test.go
+ * @description: node_server 单测
+ */
+
+func TestParseMountFlags(t *testing.T) {
+	tests := []struct {
+		name         string
+		mounter      string
+		mountFlags   []string
+		deny         []string
+		wantReadOnly bool
+		wantFlags    []string
+		wantErr      bool
+	}{
+		{
+			name:       "Test no flags",
+			mountFlags: nil,
+			wantFlags:  []string{},
+		},
+		{
+			name:         "Test ro is applied to the bind mount",
+			mountFlags:   []string{"ro", "nonempty"},
+			wantReadOnly: true,
+			wantFlags:    []string{"-o", "nonempty"},
+		},
+		{
+			name:       "Test comma separated flags are split, sorted and deduplicated",
+			mountFlags: []string{"retries=3,kernel_cache", "nonempty", "retries=3", "rw"},
+			wantFlags:  []string{"-o", "kernel_cache", "-o", "nonempty", "-o", "retries=3"},
+		},
+		{
+			name:       "Test flags of a mounter without FUSE options",
+			mounter:    "mountpoint-s3",
+			mountFlags: []string{"read_only", "max_threads=8"},
+			wantFlags:  []string{"--max-threads=8", "--read-only"},
+		},
+		{
+			name:       "Test unknown s3fs flag redirecting the credentials",
+			mountFlags: []string{"url=http://attacker"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test unknown s3fs flag disabling TLS verification",
+			mountFlags: []string{"ssl_verify_hostname=0"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test unknown mountpoint-s3 flags",
+			mounter:    "mountpoint-s3",
+			mountFlags: []string{"endpoint_url=http://attacker", "cache=/etc"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test flag denied by the administrator",
+			mountFlags: []string{"kernel_cache"},
+			deny:       []string{"s3fs:kernel_cache"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test invalid value",
+			mountFlags: []string{"retries=many"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test driver managed flag",
+			mountFlags: []string{"passwd_file=/etc/shadow"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test flag injection",
+			mountFlags: []string{"nonempty -o passwd_file=/tmp/x"},
+			wantErr:    true,
+		},
+		{
+			name:       "Test dash prefixed flag",
+			mountFlags: []string{"--debug"},
+			wantErr:    true,
+		},
+	}
+
+	defer mounter.SetOptionPolicy(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mounter.SetOptionPolicy(nil, tt.deny)
+			readOnly, flags, err := parseMountFlags(tt.mounter, tt.mountFlags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMountFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if readOnly != tt.wantReadOnly {
+				t.Errorf("parseMountFlags() readOnly = %v, want %v", readOnly, tt.wantReadOnly)
+			}
+			if !reflect.DeepEqual(flags, tt.wantFlags) {
+				t.Errorf("parseMountFlags() flags = %v, want %v", flags, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestCheckStagedMount(t *testing.T) {
+	n := NewNodeServer(&Driver{})
+
+	staged := stagedMount{fuseFlags: []string{"allow_other"}}
+	if err := n.checkStagedMount("/staging", staged); err != nil {
+		t.Fatalf("checkStagedMount() adopting unknown staging path: %v", err)
+	}
+	if err := n.checkStagedMount("/staging", staged); err != nil {
+		t.Errorf("checkStagedMount() with identical options: %v", err)
+	}
+	if err := n.checkStagedMount("/staging", stagedMount{fuseFlags: []string{"allow_other", "uid=1000"}}); err == nil {
+		t.Errorf("checkStagedMount() with conflicting flags: expected error")
+	}
+	if err := n.checkStagedMount("/staging", stagedMount{fuseFlags: []string{"allow_other"}, mountGroup: "2000"}); err == nil {
+		t.Errorf("checkStagedMount() with conflicting mount group: expected error")
+	}
+}
+
+func TestGetMeta(t *testing.T) {
+	uid, gid, umask := uint32(1000), uint32(2000), uint32(0022)
+	tests := []struct {
+		name             string
+		context          map[string]string
+		mountPermissions uint64
+		want             *utils.Metadata
+		wantErr          bool
+	}{
+		{
+			name:             "Test driver default permissions",
+			context:          map[string]string{"mounter": "s3fs"},
+			mountPermissions: 0750,
+			want: &utils.Metadata{
+				BucketName:       "bucket",
+				Mounter:          "s3fs",
+				MountOptions:     []string{},
+				MountPermissions: 0750,
+			},
+		},
+		{
+			name: "Test ownership parameters",
+			context: map[string]string{
+				MountPermissionsField: "0770",
+				ParamUID:              "1000",
+				ParamGID:              "2000",
+				ParamUmask:            "022",
+			},
+			mountPermissions: 0750,
+			want: &utils.Metadata{
+				BucketName:       "bucket",
+				MountOptions:     []string{},
+				MountPermissions: 0770,
+				UID:              &uid,
+				GID:              &gid,
+				Umask:            &umask,
+			},
+		},
+		{
+			name:    "Test cache directory outside the cache root",
+			context: map[string]string{ParamCacheSize: "1Gi", ParamCacheDir: "/etc"},
+			wantErr: true,
+		},
+		{
+			name:    "Test cache directory without cache size",
+			context: map[string]string{ParamCacheDir: "pvc-1"},
+			wantErr: true,
+		},
+		{
+			name:    "Test invalid mount permissions",
+			context: map[string]string{MountPermissionsField: "1777"},
+			wantErr: true,
+		},
+		{
+			name:    "Test invalid uid",
+			context: map[string]string{ParamUID: "root"},
+			wantErr: true,
+		},
+		{
+			name:    "Test invalid umask",
+			context: map[string]string{ParamUmask: "999"},
+			wantErr: true,
+		},
+		{
+			name:    "Test options are normalized",
+			context: map[string]string{"options": "-o use_path_request_style -o multipart_size=64"},
+			want: &utils.Metadata{
+				BucketName:   "bucket",
+				MountOptions: []string{"-o", "use_path_request_style", "-o", "multipart_size=64"},
+			},
+		},
+		{
+			name:    "Test unknown option",
+			context: map[string]string{"options": "-o passwd_file=/etc/shadow"},
+			wantErr: true,
+		},
+		{
+			name:    "Test sub-path escaping the volume",
+			context: map[string]string{ParamSubPath: "models/../../other"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getMeta("bucket", "", tt.context, tt.mountPermissions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getMeta() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getMeta() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestNodeServer returns a node server whose FUSE mounts are handled by a fake mounter
+// and whose bind mounts are recorded by a mount.FakeMounter.
+func newTestNodeServer() (*NodeServer, *mounter.FakeMounter) {
+	fake := mounter.NewFakeMounter()
+	n := NewNodeServer(&Driver{VolumeLocks: utils.NewVolumeLocks()})
+	n.newMounter = fake.New
+	n.mounterForMountPoint = fake.ForMountPoint
+	n.mountUtils = mount.NewFakeMounter(nil)
+	return n, fake
+}
+
+func TestNodeStageUnstageVolume(t *testing.T) {
+	n, fake := newTestNodeServer()
+	stagingPath := filepath.Join(t.TempDir(), "globalmount")
+
+	_, err := n.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags:       []string{"nonempty"},
+				VolumeMountGroup: "3000",
+			}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+		VolumeContext: map[string]string{"mounter": "s3fs"},
+		Secrets:       map[string]string{"endpoint": "http://127.0.0.1:9000"},
+	})
+	if err != nil {
+		t.Fatalf("NodeStageVolume() error = %v", err)
+	}
+	if !fake.IsMounted(stagingPath) {
+		t.Fatalf("NodeStageVolume() did not mount %s", stagingPath)
+	}
+	meta := fake.Metas[0]
+	if meta.BucketName != "bucket" || meta.Prefix != "pvc-1" {
+		t.Errorf("NodeStageVolume() mounted bucket %q prefix %q", meta.BucketName, meta.Prefix)
+	}
+	if meta.GID == nil || *meta.GID != 3000 {
+		t.Errorf("NodeStageVolume() did not apply the volume mount group, GID = %v", meta.GID)
+	}
+	if !reflect.DeepEqual(meta.MountFlags, []string{"-o", "nonempty"}) {
+		t.Errorf("NodeStageVolume() mount flags = %v", meta.MountFlags)
+	}
+
+	if _, err := n.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+	}); err != nil {
+		t.Fatalf("NodeUnstageVolume() error = %v", err)
+	}
+	if fake.IsMounted(stagingPath) {
+		t.Errorf("NodeUnstageVolume() did not unmount %s", stagingPath)
+	}
+	if _, ok := n.stagedMounts[stagingPath]; ok {
+		t.Errorf("NodeUnstageVolume() did not forget the staged mount %s", stagingPath)
+	}
+}
+
+func TestNodeVolumeLocks(t *testing.T) {
+	n, _ := newTestNodeServer()
+	dir := t.TempDir()
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	secrets := map[string]string{"endpoint": "http://127.0.0.1:9000"}
+	stagingPath := filepath.Join(dir, "globalmount")
+	targetPath := filepath.Join(dir, "mount")
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "Test NodeStageVolume",
+			call: func() error {
+				_, err := n.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{VolumeId: "bucket/pvc-1", StagingTargetPath: stagingPath, VolumeCapability: capability, Secrets: secrets})
+				return err
+			},
+		},
+		{
+			name: "Test NodeUnstageVolume",
+			call: func() error {
+				_, err := n.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{VolumeId: "bucket/pvc-1", StagingTargetPath: stagingPath})
+				return err
+			},
+		},
+		{
+			name: "Test NodePublishVolume",
+			call: func() error {
+				_, err := n.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{VolumeId: "bucket/pvc-1", StagingTargetPath: stagingPath, TargetPath: targetPath, VolumeCapability: capability})
+				return err
+			},
+		},
+		{
+			name: "Test NodeUnpublishVolume",
+			call: func() error {
+				_, err := n.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: "bucket/pvc-1", TargetPath: targetPath})
+				return err
+			},
+		},
+	}
+
+	n.volumeLocks.TryAcquire("bucket/pvc-1")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); status.Code(err) != codes.Aborted {
+				t.Errorf("error = %v, want code %v", err, codes.Aborted)
+			}
+		})
+	}
+	// stats do not wait for the operations on the volume
+	if _, err := n.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{VolumeId: "bucket/pvc-1", VolumePath: dir}); err != nil {
+		t.Errorf("NodeGetVolumeStats() of a locked volume error = %v", err)
+	}
+	n.volumeLocks.Release("bucket/pvc-1")
+	if err := tests[0].call(); err != nil {
+		t.Errorf("NodeStageVolume() after release error = %v", err)
+	}
+}
+
+func TestNodeStageVolumeConcurrently(t *testing.T) {
+	n, fake := newTestNodeServer()
+	stagingPath := filepath.Join(t.TempDir(), "globalmount")
+	req := &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+		Secrets: map[string]string{"endpoint": "http://127.0.0.1:9000"},
+	}
+	// the fake mounter leaves the staging path unmounted, so every call that gets the lock mounts it,
+	// record how many calls mount at the same time
+	var mu sync.Mutex
+	var mounting, maxMounting int
+	n.newMounter = func(meta *utils.Metadata, cfg *utils.Config) (mounter.Mounter, error) {
+		mu.Lock()
+		mounting++
+		maxMounting = max(maxMounting, mounting)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		mounting--
+		mu.Unlock()
+		return fake.New(meta, cfg)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = n.NodeStageVolume(context.Background(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && status.Code(err) != codes.Aborted {
+			t.Errorf("NodeStageVolume() error = %v, want nil or code %v", err, codes.Aborted)
+		}
+	}
+	if maxMounting != 1 {
+		t.Errorf("NodeStageVolume() mounted %s %d times concurrently", stagingPath, maxMounting)
+	}
+	if !fake.IsMounted(stagingPath) {
+		t.Errorf("NodeStageVolume() did not mount %s", stagingPath)
+	}
+}
+
+func TestNodeUnpublishVolumeUnstagesLastBindMount(t *testing.T) {
+	n, fake := newTestNodeServer()
+	dir := t.TempDir()
+	stagingPath := filepath.Join(dir, "globalmount")
+	targets := []string{filepath.Join(dir, "pod-1"), filepath.Join(dir, "pod-2")}
+
+	_, err := n.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+		},
+		Secrets: map[string]string{"endpoint": "http://127.0.0.1:9000"},
+	})
+	if err != nil {
+		t.Fatalf("NodeStageVolume() error = %v", err)
+	}
+
+	unpublish := func(target string) {
+		if _, err := n.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+			VolumeId:   "bucket/pvc-1",
+			TargetPath: target,
+		}); err != nil {
+			t.Fatalf("NodeUnpublishVolume() error = %v", err)
+		}
+	}
+
+	testutil.FakeMountInfo(t, &procMountInfoPath, testutil.Mount{MountPoint: stagingPath, Device: "0:51"}, testutil.Mount{MountPoint: targets[0], Device: "0:51"}, testutil.Mount{MountPoint: targets[1], Device: "0:51"})
+	unpublish(targets[0])
+	if !fake.IsMounted(stagingPath) {
+		t.Fatalf("NodeUnpublishVolume() unstaged %s while %s is still mounted", stagingPath, targets[1])
+	}
+
+	testutil.FakeMountInfo(t, &procMountInfoPath, testutil.Mount{MountPoint: stagingPath, Device: "0:51"}, testutil.Mount{MountPoint: targets[1], Device: "0:51"})
+	unpublish(targets[1])
+	if fake.IsMounted(stagingPath) {
+		t.Errorf("NodeUnpublishVolume() did not unstage %s after its last bind mount", stagingPath)
+	}
+	if _, ok := n.stagedMounts[stagingPath]; ok {
+		t.Errorf("NodeUnpublishVolume() did not forget the staged mount %s", stagingPath)
+	}
+
+	// unpublishing and unstaging again succeeds
+	testutil.FakeMountInfo(t, &procMountInfoPath)
+	unpublish(targets[1])
+	if _, err := n.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+	}); err != nil {
+		t.Errorf("NodeUnstageVolume() error = %v", err)
+	}
+	if _, err := os.Stat(stagingPath); !os.IsNotExist(err) {
+		t.Errorf("NodeUnstageVolume() did not remove %s", stagingPath)
+	}
+}
+
+func TestNodePublishVolume(t *testing.T) {
+	n, _ := newTestNodeServer()
+	dir := t.TempDir()
+	stagingPath := filepath.Join(dir, "globalmount")
+	targetPath := filepath.Join(dir, "mount")
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+	}
+	secrets := map[string]string{"endpoint": "http://127.0.0.1:9000"}
+
+	if _, err := n.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  capability,
+		Secrets:           secrets,
+	}); err != nil {
+		t.Fatalf("NodeStageVolume() error = %v", err)
+	}
+	fakeMounts := n.mountUtils.(*mount.FakeMounter)
+	// the FUSE mount of the fake mounter is not in the mount table
+	fakeMounts.MountPoints = append(fakeMounts.MountPoints, mount.MountPoint{Device: "s3fs", Path: stagingPath, Type: "fuse.s3fs"})
+
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		TargetPath:        targetPath,
+		VolumeCapability:  capability,
+		Secrets:           secrets,
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := n.NodePublishVolume(context.Background(), req); err != nil {
+			t.Fatalf("NodePublishVolume() error = %v", err)
+		}
+	}
+
+	mountPoints, err := fakeMounts.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var binds []mount.MountPoint
+	for _, mp := range mountPoints {
+		if mp.Path == targetPath {
+			binds = append(binds, mp)
+		}
+	}
+	if len(binds) != 1 {
+		t.Fatalf("NodePublishVolume() mounted %s %d times, want once", targetPath, len(binds))
+	}
+	// a bind mount shows the device of its source
+	if binds[0].Device != "s3fs" || !reflect.DeepEqual(binds[0].Opts, []string{"bind", "ro"}) {
+		t.Errorf("NodePublishVolume() mounted %s at %s with options %v, want a read-only bind mount of %s", binds[0].Device, targetPath, binds[0].Opts, stagingPath)
+	}
+}
+
+func TestNodePublishVolumeSubPath(t *testing.T) {
+	n, _ := newTestNodeServer()
+	dir := t.TempDir()
+	stagingPath := filepath.Join(dir, "globalmount")
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+	}
+	secrets := map[string]string{"endpoint": "http://127.0.0.1:9000"}
+	if _, err := n.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  capability,
+		Secrets:           secrets,
+	}); err != nil {
+		t.Fatalf("NodeStageVolume() error = %v", err)
+	}
+	fakeMounts := n.mountUtils.(*mount.FakeMounter)
+	fakeMounts.MountPoints = append(fakeMounts.MountPoints, mount.MountPoint{Device: "s3fs", Path: stagingPath, Type: "fuse.s3fs"})
+
+	publish := func(target string, volumeContext map[string]string) error {
+		_, err := n.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+			VolumeId:          "bucket/pvc-1",
+			StagingTargetPath: stagingPath,
+			TargetPath:        filepath.Join(dir, target),
+			VolumeCapability:  capability,
+			VolumeContext:     volumeContext,
+			Secrets:           secrets,
+		})
+		return err
+	}
+
+	if err := publish("missing", map[string]string{ParamSubPath: "models/v3"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("NodePublishVolume() of a missing sub-path error = %v, want code %v", err, codes.FailedPrecondition)
+	}
+	if err := publish("models", map[string]string{ParamSubPath: "models/v3", ParamSubPathCreate: "true"}); err != nil {
+		t.Fatalf("NodePublishVolume() error = %v", err)
+	}
+	if err := publish("escape", map[string]string{ParamSubPath: "../.."}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("NodePublishVolume() of an escaping sub-path error = %v, want code %v", err, codes.InvalidArgument)
+	}
+
+	var sources []string
+	for _, action := range fakeMounts.GetLog() {
+		if action.Action == mount.FakeActionMount {
+			sources = append(sources, action.Source)
+		}
+	}
+	// the sub-path is bind mounted through the descriptor of the opened directory
+	if len(sources) != 1 || !strings.HasPrefix(sources[0], fmt.Sprintf("/proc/%d/fd/", os.Getpid())) {
+		t.Errorf("NodePublishVolume() bind mounted %v, want a descriptor of %s", sources, filepath.Join(stagingPath, "models", "v3"))
+	}
+	if info, err := os.Stat(filepath.Join(stagingPath, "models", "v3")); err != nil || !info.IsDir() {
+		t.Errorf("NodePublishVolume() did not create the sub-path: %v", err)
+	}
+}
+
+func TestNodePublishEphemeralVolume(t *testing.T) {
+	n, fake := newTestNodeServer()
+	targetPath := filepath.Join(t.TempDir(), "mount")
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId:   "csi-0123456789",
+		TargetPath: targetPath,
+		Readonly:   true,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+		VolumeContext: map[string]string{
+			EphemeralKey: "true",
+			ParamBucket:  "datasets",
+			ParamPrefix:  "imagenet",
+		},
+		Secrets: map[string]string{"endpoint": "http://127.0.0.1:9000"},
+	}
+
+	if _, err := n.NodePublishVolume(context.Background(), req); err == nil {
+		t.Fatalf("NodePublishVolume() expected error while ephemeral volumes are disabled")
+	}
+
+	n.enableEphemeral = true
+	if _, err := n.NodePublishVolume(context.Background(), req); err != nil {
+		t.Fatalf("NodePublishVolume() error = %v", err)
+	}
+	if !fake.IsMounted(targetPath) {
+		t.Fatalf("NodePublishVolume() did not mount %s", targetPath)
+	}
+	meta := fake.Metas[0]
+	if meta.BucketName != "datasets" || meta.Prefix != "imagenet" || !meta.ReadOnly {
+		t.Errorf("NodePublishVolume() mounted bucket %q prefix %q read-only %v", meta.BucketName, meta.Prefix, meta.ReadOnly)
+	}
+
+	if _, err := n.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "csi-0123456789",
+		TargetPath: targetPath,
+	}); err != nil {
+		t.Fatalf("NodeUnpublishVolume() error = %v", err)
+	}
+	if fake.IsMounted(targetPath) {
+		t.Errorf("NodeUnpublishVolume() did not unmount %s", targetPath)
+	}
+	if _, ok := n.ephemeralMounts[targetPath]; ok {
+		t.Errorf("NodeUnpublishVolume() did not forget the ephemeral mount %s", targetPath)
+	}
+	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
+		t.Errorf("NodeUnpublishVolume() did not remove %s", targetPath)
+	}
+
+	delete(req.VolumeContext, ParamBucket)
+	if _, err := n.NodePublishVolume(context.Background(), req); err == nil {
+		t.Errorf("NodePublishVolume() expected error for ephemeral volume without bucket")
+	}
+}
+
+func TestNodeVolumeCache(t *testing.T) {
+	n, fake := newTestNodeServer()
+	caches, err := newCacheManager(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("newCacheManager() error = %v", err)
+	}
+	n.caches = caches
+	stagingPath := filepath.Join(t.TempDir(), "globalmount")
+
+	if _, err := n.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+		VolumeContext: map[string]string{"mounter": "rclone", ParamCacheSize: "1Mi", ParamCacheDir: "pvc-1"},
+		Secrets:       map[string]string{"endpoint": "http://127.0.0.1:9000"},
+	}); err != nil {
+		t.Fatalf("NodeStageVolume() error = %v", err)
+	}
+	meta := fake.Metas[0]
+	if meta.CacheDir != filepath.Join(caches.root, "pvc-1") || meta.CacheSize != 1<<20 {
+		t.Errorf("NodeStageVolume() cache dir %q size %d", meta.CacheDir, meta.CacheSize)
+	}
+	if err := os.WriteFile(filepath.Join(meta.CacheDir, "object"), make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := n.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+		VolumeId:          "bucket/pvc-1",
+		VolumePath:        stagingPath,
+		StagingTargetPath: stagingPath,
+	})
+	if err != nil {
+		t.Fatalf("NodeGetVolumeStats() error = %v", err)
+	}
+	want := []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES, Total: 1 << 20, Used: 1024, Available: 1<<20 - 1024}}
+	if !reflect.DeepEqual(resp.GetUsage(), want) {
+		t.Errorf("NodeGetVolumeStats() usage = %v, want %v", resp.GetUsage(), want)
+	}
+
+	if _, err := n.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: stagingPath,
+	}); err != nil {
+		t.Fatalf("NodeUnstageVolume() error = %v", err)
+	}
+	if _, err := os.Stat(meta.CacheDir); !os.IsNotExist(err) {
+		t.Errorf("NodeUnstageVolume() did not delete the cache %s", meta.CacheDir)
+	}
+}
@@ -1,6 +1,8 @@
 package driver
 
 import (
+	"crypto/tls"
+	"errors"
 	"net"
 	"os"
 	"sync"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"k8s.io/klog/v2"
 )
 
@@ -22,31 +25,64 @@ import (
 
 type NonBlockingGRPCServer interface {
 	// Start starts the gRPC server and blocks until the server exits.
-	Start(endponit string, is csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer, mode bool)
+	Start(endponit string, is csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer)
 	// WaitForStop blocks until the server is stopped.
 	Wait()
 	// Stop stops the gRPC server.
 	Stop()
 	// ForceStop stops the gRPC server immediately.
 	ForceStop()
+	// Shutdown drains the in-flight calls, asks them to checkpoint after timeout and then stops the server.
+	Shutdown(timeout time.Duration, checkpoint func())
 }
 
+// checkpointTimeout is how long the calls still running at the shutdown timeout get to
+// checkpoint before they are canceled.
+var checkpointTimeout = 5 * time.Second
+
 // NonBlockingGRPCServerOptions contains the options for a non-blocking gRPC server.
 type NonBlockingGRPCServerOptions struct {
 	wg     *sync.WaitGroup
 	server *grpc.Server
+	nodeID string // nodeID is logged with every call.
+	// tlsConfig secures the connections, nil serves them without TLS.
+	tlsConfig *tls.Config
 }
 
-// NewNonBlockingGRPCServerOptions returns a new NonBlockingGRPCServerOptions.
-func NewNonBlockingGRPCServerOptions() *NonBlockingGRPCServerOptions {
-	return &NonBlockingGRPCServerOptions{}
+// NewNonBlockingGRPCServerOptions returns a new NonBlockingGRPCServerOptions logging the calls with the node ID.
+func NewNonBlockingGRPCServerOptions(nodeID string) *NonBlockingGRPCServerOptions {
+	return &NonBlockingGRPCServerOptions{wg: &sync.WaitGroup{}, nodeID: nodeID}
 }
 
-// Start starts the gRPC server and blocks until the server exits.
-func (s *NonBlockingGRPCServerOptions) Start(endponit string, is csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer, mode bool) {
-	s.wg.Add(1)
+// SetTLSConfig serves the connections with TLS, it must be called before Start.
+func (s *NonBlockingGRPCServerOptions) SetTLSConfig(config *tls.Config) {
+	s.tlsConfig = config
+}
+
+// Start starts the gRPC server in the background, Wait blocks until the server exits.
+// The services are registered before Start returns, so the server can be stopped at once.
+func (s *NonBlockingGRPCServerOptions) Start(endponit string, is csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(utils.TraceGRPC, utils.MetricsGRPC, utils.LogGRPC(s.nodeID)),
+	}
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	s.server = server
+
+	if is != nil {
+		csi.RegisterIdentityServer(server, is)
+	}
+	if cs != nil {
+		csi.RegisterControllerServer(server, cs)
+	}
+	if ns != nil {
+		csi.RegisterNodeServer(server, ns)
+	}
 
-	go s.serve(endponit, is, cs, ns, mode)
+	s.wg.Add(1)
+	go s.serve(endponit)
 }
 
 // WaitForStop blocks until the server is stopped.
@@ -64,15 +100,46 @@ func (s *NonBlockingGRPCServerOptions) ForceStop() {
 	s.server.Stop()
 }
 
-// serve starts the gRPC server and serves the given services.
-func (s *NonBlockingGRPCServerOptions) serve(endpoint string, is csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer, mode bool) {
+// Shutdown stops accepting calls and drains the in-flight ones for up to timeout. The calls
+// still running then are told to checkpoint by checkpoint, and are canceled after the
+// checkpoint timeout. It returns once the server is stopped.
+func (s *NonBlockingGRPCServerOptions) Shutdown(timeout time.Duration, checkpoint func()) {
+	drained := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return
+	case <-time.After(timeout):
+	}
+	klog.Warningf("In-flight calls did not finish within %v, asking them to checkpoint", timeout)
+	checkpoint()
+
+	select {
+	case <-drained:
+		return
+	case <-time.After(checkpointTimeout):
+	}
+	klog.Warningf("In-flight calls did not checkpoint within %v, canceling them", checkpointTimeout)
+	s.ForceStop()
+	<-drained
+}
+
+// serve listens on the endpoint and serves the registered services until the server is stopped.
+// The unix socket is removed on exit.
+func (s *NonBlockingGRPCServerOptions) serve(endpoint string) {
+	defer s.wg.Done()
+
 	proto, addr, err := utils.ParseEndpoint(endpoint)
 	if err != nil {
 		klog.Fatal(err.Error())
 	}
 
 	if proto == "unix" {
-		addr = "/" + addr
+		addr = utils.UnixSocketPath(addr)
 		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
 			klog.Fatalf("Failed to remove %s, error: %s", addr, err.Error())
 		}
@@ -83,37 +150,19 @@ func (s *NonBlockingGRPCServerOptions) serve(endpoint string, is csi.IdentitySer
 		klog.Fatalf("Failed to listen: %v", err)
 	}
 
-	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(utils.LogGRPC),
-	}
-	server := grpc.NewServer(opts...)
-	s.server = server
-
-	if is != nil {
-		csi.RegisterIdentityServer(server, is)
-	}
-	if cs != nil {
-		csi.RegisterControllerServer(server, cs)
-	}
-	if ns != nil {
-		csi.RegisterNodeServer(server, ns)
-	}
-
-	// Used to stop the server while running tests
-	if mode {
-		s.wg.Done()
-		go func() {
-			// make sure Serve() is called
-			s.wg.Wait()
-			time.Sleep(time.Millisecond * 1000)
-			s.server.GracefulStop()
+	if proto == "unix" {
+		defer func() {
+			if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+				klog.Warningf("Failed to remove %s: %v", addr, err)
+			}
 		}()
 	}
 
 	klog.Infof("Listening for connections on address: %#v", listener.Addr())
 
-	err = server.Serve(listener)
-	if err != nil {
+	// a server stopped before it served returns ErrServerStopped
+	if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
 		klog.Fatalf("Failed to serve grpc server: %v", err)
 	}
+	klog.Infof("Stopped serving on address: %#v", listener.Addr())
 }
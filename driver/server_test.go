@@ -0,0 +1,277 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	"github.com/keington/s3-csi-driver/driver/utils/tracing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	logsapi "k8s.io/component-base/logs/api/v1"
+	logsjson "k8s.io/component-base/logs/json"
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 00:06:40
+ * @file: server_test.go
+ * @description: server 单测
+ */
+
+func TestTraceGRPC(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), tracing.Options{SampleRatio: 1})
+	oldProvider, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(oldProvider)
+		otel.SetTextMapPropagator(oldPropagator)
+	}()
+
+	// the call continues the trace of the sidecar
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodeStageVolume"}
+	_, err := utils.TraceGRPC(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		_, span := tracing.Start(ctx, "mountVolume")
+		tracing.End(span, nil)
+		return nil, status.Error(grpccodes.Aborted, "operation already in progress")
+	})
+	if status.Code(err) != grpccodes.Aborted {
+		t.Fatalf("TraceGRPC() error = %v, want the error of the handler", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	mount, call := spans[0], spans[1]
+	if call.Name != info.FullMethod {
+		t.Errorf("span name = %s, want %s", call.Name, info.FullMethod)
+	}
+	if got := call.Parent.TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" || !call.Parent.IsRemote() {
+		t.Errorf("span parent = %v, want the remote span of the sidecar", call.Parent)
+	}
+	if mount.Parent.SpanID() != call.SpanContext.SpanID() {
+		t.Errorf("span %s is not a child of %s", mount.Name, call.Name)
+	}
+	if call.Status.Code != codes.Error {
+		t.Errorf("span status = %v, want error", call.Status)
+	}
+	want := attribute.String("rpc.grpc.status_code", grpccodes.Aborted.String())
+	found := false
+	for _, attr := range call.Attributes {
+		found = found || attr == want
+	}
+	if !found {
+		t.Errorf("span attributes = %v, want %v", call.Attributes, want)
+	}
+}
+
+func TestLogGRPC(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := logsjson.NewJSONLogger(4, zapcore.AddSync(&buf), nil, nil)
+	ctx := klog.NewContext(context.Background(), logger)
+	interceptor := utils.LogGRPC("node-1")
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodeStageVolume"}
+	req := &csi.NodeStageVolumeRequest{
+		VolumeId:          "bucket/pvc-1",
+		StagingTargetPath: "/staging",
+		Secrets:           map[string]string{"secretAccessKey": "top-secret"},
+	}
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		klog.FromContext(ctx).V(2).Info("Staged volume")
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := interceptor(ctx, req, info, handler); err != nil {
+			t.Fatalf("LogGRPC() error = %v", err)
+		}
+	}
+
+	if strings.Contains(buf.String(), "top-secret") {
+		t.Errorf("secrets are logged: %s", buf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("got %d log lines, want 6:\n%s", len(lines), buf.String())
+	}
+	requestIDs := map[string]int{}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %s is not JSON: %v", line, err)
+		}
+		for key, want := range map[string]string{"method": info.FullMethod, "volumeID": "bucket/pvc-1", "nodeID": "node-1"} {
+			if entry[key] != want {
+				t.Errorf("log line %s: %s = %v, want %s", line, key, entry[key], want)
+			}
+		}
+		id, _ := entry["requestID"].(string)
+		if id == "" {
+			t.Errorf("log line %s has no request ID", line)
+		}
+		requestIDs[id]++
+	}
+	if len(requestIDs) != 2 {
+		t.Errorf("request IDs = %v, want one per call", requestIDs)
+	}
+}
+
+func TestApplyLogging(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantGlog string
+		wantErr  bool
+	}{
+		{name: "Test default", wantGlog: "0"},
+		{name: "Test text", args: []string{"--logging-format=text", "-v=3"}, wantGlog: "3"},
+		{name: "Test unknown", args: []string{"--logging-format=yaml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandLine := flag.CommandLine
+			glogVerbosity := commandLine.Lookup("v").Value.String()
+			defer func() {
+				flag.CommandLine = commandLine
+				_ = commandLine.Set("v", glogVerbosity)
+				_ = logsapi.ResetForTest(nil)
+			}()
+
+			c := logsapi.NewLoggingConfiguration()
+			utils.SetupLoggingFlags(c)
+			if err := flag.CommandLine.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if err := utils.ApplyLogging(c); (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyLogging() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := commandLine.Lookup("v").Value.String(); !tt.wantErr && got != tt.wantGlog {
+				t.Errorf("glog -v = %v, want %v", got, tt.wantGlog)
+			}
+		})
+	}
+}
+
+// probeServer answers probes with the result of probe, after telling started
+type probeServer struct {
+	csi.UnimplementedIdentityServer
+	started chan struct{}
+	probe   func(ctx context.Context) error
+}
+
+// Probe implements csi.IdentityServer.
+func (p *probeServer) Probe(ctx context.Context, _ *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	close(p.started)
+	if err := p.probe(ctx); err != nil {
+		return nil, err
+	}
+	return &csi.ProbeResponse{}, nil
+}
+
+func TestShutdown(t *testing.T) {
+	oldCheckpointTimeout := checkpointTimeout
+	checkpointTimeout = 200 * time.Millisecond
+	defer func() { checkpointTimeout = oldCheckpointTimeout }()
+
+	tests := []struct {
+		name string
+		// probe runs the in-flight call, checkpointed is closed by the checkpoint of the shutdown
+		probe          func(ctx context.Context, checkpointed chan struct{}) error
+		wantCode       grpccodes.Code
+		wantCheckpoint bool
+	}{
+		{
+			name: "Test drained",
+			probe: func(ctx context.Context, checkpointed chan struct{}) error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+			wantCode: grpccodes.OK,
+		},
+		{
+			name: "Test checkpointed",
+			probe: func(ctx context.Context, checkpointed chan struct{}) error {
+				<-checkpointed
+				return status.Error(grpccodes.Unavailable, "interrupted, retry")
+			},
+			wantCode:       grpccodes.Unavailable,
+			wantCheckpoint: true,
+		},
+		{
+			name: "Test canceled",
+			probe: func(ctx context.Context, checkpointed chan struct{}) error {
+				<-ctx.Done()
+				return status.FromContextError(ctx.Err()).Err()
+			},
+			wantCode:       grpccodes.Unavailable,
+			wantCheckpoint: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpointed := make(chan struct{})
+			is := &probeServer{started: make(chan struct{})}
+			is.probe = func(ctx context.Context) error { return tt.probe(ctx, checkpointed) }
+			socket := filepath.Join(t.TempDir(), "csi.sock")
+			s := NewNonBlockingGRPCServerOptions("node-1")
+			s.Start("unix:/"+socket, is, nil, nil)
+
+			conn, err := grpc.Dial("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			probed := make(chan error, 1)
+			go func() {
+				_, err := csi.NewIdentityClient(conn).Probe(context.Background(), &csi.ProbeRequest{})
+				probed <- err
+			}()
+			<-is.started
+
+			s.Shutdown(100*time.Millisecond, func() { close(checkpointed) })
+			s.Wait()
+
+			// the call is answered, by the server or by the client when the server is gone
+			if err := <-probed; status.Code(err) != tt.wantCode {
+				t.Errorf("Probe() error = %v, want %v", err, tt.wantCode)
+			}
+			select {
+			case <-checkpointed:
+				if !tt.wantCheckpoint {
+					t.Errorf("Shutdown() asked a drained call to checkpoint")
+				}
+			default:
+				if tt.wantCheckpoint {
+					t.Errorf("Shutdown() did not ask the call to checkpoint")
+				}
+			}
+			if _, err := os.Stat(socket); !os.IsNotExist(err) {
+				t.Errorf("socket %s is not removed: %v", socket, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 22:47:12
+ * @file: subpath.go
+ * @description: 发布暂存卷的子目录，拒绝越出卷根目录的路径与符号链接
+ */
+
+// cleanSubPath returns the sub-path as a clean relative path, or an error if it leaves the volume root.
+// The volume root itself is returned as ".".
+func cleanSubPath(subPath string) (string, error) {
+	if filepath.IsAbs(subPath) {
+		return "", fmt.Errorf("invalid %s %q: must be a relative path", ParamSubPath, subPath)
+	}
+	for _, element := range strings.Split(subPath, "/") {
+		if element == ".." {
+			return "", fmt.Errorf("invalid %s %q: must not contain '..'", ParamSubPath, subPath)
+		}
+	}
+	return filepath.Clean(subPath), nil
+}
+
+// subPathMode returns the mode of the sub-path directories created on demand, it follows the
+// directory mode the FUSE mount shows for the volume.
+func subPathMode(meta *utils.Metadata) os.FileMode {
+	if meta.Umask != nil {
+		return os.FileMode(0777 &^ *meta.Umask)
+	}
+	if meta.MountPermissions != 0 {
+		return os.FileMode(meta.MountPermissions)
+	}
+	return 0755
+}
+
+// openSubPath opens the directory of the sub-path under root with O_PATH. Every element is opened
+// relative to its parent without following symbolic links, so the result cannot point outside root
+// even if a pod replaces a directory with a link meanwhile. Missing directories are created with
+// mode if create is set. The caller must close the returned file.
+func openSubPath(root, subPath string, create bool, mode os.FileMode) (*os.File, error) {
+	subPath, err := cleanSubPath(subPath)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	fd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to open %s: %v", root, err)
+	}
+	path := root
+	if subPath != "." {
+		for _, element := range strings.Split(subPath, string(filepath.Separator)) {
+			path = filepath.Join(path, element)
+			child, err := openSubPathElement(fd, element, subPath, create, mode)
+			unix.Close(fd)
+			if err != nil {
+				return nil, err
+			}
+			fd = child
+		}
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// openSubPathElement opens the directory element below the directory parent, creating it if create is set.
+func openSubPathElement(parent int, element, subPath string, create bool, mode os.FileMode) (int, error) {
+	created := false
+	fd, err := unix.Openat(parent, element, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err == unix.ENOENT {
+		if !create {
+			return -1, status.Errorf(codes.FailedPrecondition, "%s %s does not exist in the volume, set %s to create it", ParamSubPath, subPath, ParamSubPathCreate)
+		}
+		switch err := unix.Mkdirat(parent, element, uint32(mode.Perm())); err {
+		case nil:
+			created = true
+		case unix.EEXIST:
+		default:
+			return -1, status.Errorf(codes.Internal, "failed to create %s %s: %v", ParamSubPath, subPath, err)
+		}
+		// another publish may have created something else meanwhile
+		fd, err = unix.Openat(parent, element, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	}
+	if err != nil {
+		return -1, status.Errorf(codes.Internal, "failed to open %s %s: %v", ParamSubPath, subPath, err)
+	}
+	var stat unix.Stat_t
+	if err := unix.Fstat(fd, &stat); err != nil {
+		unix.Close(fd)
+		return -1, status.Errorf(codes.Internal, "failed to stat %s %s: %v", ParamSubPath, subPath, err)
+	}
+	switch stat.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		if created {
+			// mkdir applies the umask of the driver process, set the mode of the volume through the descriptor
+			if err := os.Chmod(fmt.Sprintf("/proc/self/fd/%d", fd), mode.Perm()); err != nil {
+				unix.Close(fd)
+				return -1, status.Errorf(codes.Internal, "failed to set the mode of %s %s: %v", ParamSubPath, subPath, err)
+			}
+		}
+		return fd, nil
+	case unix.S_IFLNK:
+		unix.Close(fd)
+		return -1, status.Errorf(codes.InvalidArgument, "%s %s must not contain symbolic links", ParamSubPath, subPath)
+	default:
+		unix.Close(fd)
+		return -1, status.Errorf(codes.FailedPrecondition, "%s %s is not a directory", ParamSubPath, subPath)
+	}
+}
+
+// fdPath returns the path of the open file in /proc. The mount command runs in a process of its own,
+// so the descriptor is addressed through the pid of the driver.
+func fdPath(f *os.File) string {
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd())
+}
@@ -0,0 +1,143 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 22:58:30
+ * @file: subpath_test.go
+ * @description: subpath 单测
+ */
+
+func TestOpenSubPath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "datasets", "imagenet"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		subPath  string
+		create   bool
+		want     string
+		wantCode codes.Code
+	}{
+		{
+			name:    "Test existing directory",
+			subPath: "datasets/imagenet/",
+			want:    filepath.Join(root, "datasets", "imagenet"),
+		},
+		{
+			name:    "Test volume root",
+			subPath: "./",
+			want:    root,
+		},
+		{
+			name:    "Test created directory",
+			subPath: "models/v3",
+			create:  true,
+			want:    filepath.Join(root, "models", "v3"),
+		},
+		{
+			name:     "Test missing directory",
+			subPath:  "checkpoints",
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Test parent directory",
+			subPath:  "datasets/../../etc",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Test absolute path",
+			subPath:  "/etc",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Test symbolic link",
+			subPath:  "link/data",
+			create:   true,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Test file",
+			subPath:  "README",
+			wantCode: codes.FailedPrecondition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := openSubPath(root, tt.subPath, tt.create, 0770)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("openSubPath() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			defer dir.Close()
+			// the descriptor is what gets bind mounted
+			got, err := os.Readlink(fdPath(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || dir.Name() != tt.want {
+				t.Errorf("openSubPath() opened %s (%s), want %s", got, dir.Name(), tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(outside, "data")); !os.IsNotExist(err) {
+		t.Errorf("openSubPath() created a directory through a symbolic link")
+	}
+	for _, path := range []string{filepath.Join(root, "models"), filepath.Join(root, "models", "v3")} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0770 {
+			t.Errorf("openSubPath() created %s with mode %v, want %v", path, info.Mode().Perm(), os.FileMode(0770))
+		}
+	}
+}
+
+func TestSubPathMode(t *testing.T) {
+	umask := uint32(0027)
+	tests := []struct {
+		name string
+		meta *utils.Metadata
+		want os.FileMode
+	}{
+		{
+			name: "Test default",
+			meta: &utils.Metadata{},
+			want: 0755,
+		},
+		{
+			name: "Test mount permissions",
+			meta: &utils.Metadata{MountPermissions: 0777},
+			want: 0777,
+		},
+		{
+			name: "Test umask",
+			meta: &utils.Metadata{MountPermissions: 0777, Umask: &umask},
+			want: 0750,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subPathMode(tt.meta); got != tt.want {
+				t.Errorf("subPathMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,16 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
+	"github.com/keington/s3-csi-driver/driver/utils/metrics"
+	"github.com/keington/s3-csi-driver/driver/utils/tracing"
+	"github.com/google/uuid"
 	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"k8s.io/klog/v2"
 )
 
@@ -29,21 +37,73 @@ func ParseEndpoint(endpoint string) (string, string, error) {
 	return "", "", fmt.Errorf("invalid endpoint: %v", endpoint)
 }
 
-// LogGRPC is a middleware function that logs the details of a gRPC call.
-// It logs the method, request, and response using the klog package.
+// UnixSocketPath returns the path of the unix socket of the address ParseEndpoint returned.
+// The address is absolute, so unix://tmp/csi.sock and unix:///tmp/csi.sock both name /tmp/csi.sock.
+func UnixSocketPath(addr string) string {
+	return "/" + strings.TrimLeft(addr, "/")
+}
+
+// LogGRPC returns a middleware function that logs the details of a gRPC call.
+// Every call gets a generated request ID, which the logger of the call carries with the
+// method, the volume ID and the node ID to the code logging through klog.FromContext.
+// The request and response are logged with their secrets stripped.
 // The log level is determined based on the method.
-// It returns the response and any error from the handler.
-func LogGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	level := klog.Level(getLogLevel(info.FullMethod))
-	klog.V(level).Infof("GRPC call: %s", info.FullMethod)
-	klog.V(level).Infof("GRPC request: %s", protosanitizer.StripSecrets(req))
+func LogGRPC(nodeID string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		logger := klog.LoggerWithValues(klog.FromContext(ctx), callValues(ctx, nodeID, req, info.FullMethod)...)
+		ctx = klog.NewContext(ctx, logger)
+		level := int(getLogLevel(info.FullMethod))
+		logger.V(level).Info("GRPC call", "request", protosanitizer.StripSecrets(req))
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			logger.Error(err, "GRPC error")
+		} else {
+			logger.V(level).Info("GRPC response", "response", protosanitizer.StripSecrets(resp))
+		}
+		return resp, err
+	}
+}
+
+// callValues returns the key/value pairs identifying a gRPC call in the logs.
+func callValues(ctx context.Context, nodeID string, req interface{}, method string) []interface{} {
+	values := []interface{}{"requestID", uuid.NewString(), "method", method}
+	if r, ok := req.(interface{ GetVolumeId() string }); ok && r.GetVolumeId() != "" {
+		values = append(values, "volumeID", r.GetVolumeId())
+	}
+	if r, ok := req.(interface{ GetNodeId() string }); ok && r.GetNodeId() != "" {
+		nodeID = r.GetNodeId()
+	}
+	if nodeID != "" {
+		values = append(values, "nodeID", nodeID)
+	}
+	// correlate the logs with the trace of the call
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		values = append(values, "traceID", sc.TraceID().String())
+	}
+	return values
+}
 
+// MetricsGRPC is a middleware function that records the count and latency of gRPC calls.
+// The calls are counted by method and status code.
+func MetricsGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
 	resp, err := handler(ctx, req)
-	if err != nil {
-		klog.Errorf("GRPC error: %v", err)
-	} else {
-		klog.V(level).Infof("GRPC response: %s", protosanitizer.StripSecrets(resp))
+	metrics.RPCDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	metrics.RPCRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	return resp, err
+}
+
+// TraceGRPC is a middleware function that starts a span for every gRPC call.
+// The spans of the S3 requests and mount steps of the call are its children.
+func TraceGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = tracing.Extract(ctx, md)
 	}
+	ctx, span := tracing.Start(ctx, info.FullMethod, attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", info.FullMethod))
+	resp, err := handler(ctx, req)
+	span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+	tracing.End(span, err)
 	return resp, err
 }
 
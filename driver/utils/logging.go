@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+
+	logsapi "k8s.io/component-base/logs/api/v1"
+	// registers the json logging format
+	_ "k8s.io/component-base/logs/json/register"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 00:21:08
+ * @file: logging.go
+ * @description: 日志格式与级别，由 component-base 的日志配置处理，json 格式输出结构化日志
+ */
+
+// glogFlags are the flags glog registered, whose verbosity follows the one of klog
+var glogFlags *flag.FlagSet
+
+// SetupLoggingFlags replaces flag.CommandLine by a flag set with its flags and the logging flags of
+// component-base configuring c, --logging-format, -v and -vmodule among them. glog, which the CSI
+// library logs with, registers -v and -vmodule of its own, those of component-base take their place.
+func SetupLoggingFlags(c *logsapi.LoggingConfiguration) {
+	fs := flag.NewFlagSet(flag.CommandLine.Name(), flag.ExitOnError)
+	logsapi.AddGoFlags(c, fs)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) == nil {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	glogFlags = flag.CommandLine
+	flag.CommandLine = fs
+}
+
+// ApplyLogging validates the logging configuration and applies it to klog, glog logs with the same verbosity.
+func ApplyLogging(c *logsapi.LoggingConfiguration) error {
+	if err := logsapi.ValidateAndApply(c, nil); err != nil {
+		return err
+	}
+	if glogFlags == nil {
+		return nil
+	}
+	if v := glogFlags.Lookup("v"); v != nil {
+		return v.Value.Set(fmt.Sprint(c.Verbosity))
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 23:12:08
+ * @file: metrics.go
+ * @description: Prometheus 指标，覆盖 CSI 调用、挂载与 S3 操作
+ */
+
+// namespace prefixes the names of all metrics of the driver
+const namespace = "s3_csi"
+
+const (
+	// ResultSuccess labels successful operations
+	ResultSuccess = "success"
+	// ResultFailure labels failed operations
+	ResultFailure = "failure"
+)
+
+// Registry holds the metrics of the driver, it is served by Serve.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RPCRequests counts the CSI calls by method and status code.
+	RPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_requests_total",
+		Help:      "Number of CSI calls by method and gRPC status code.",
+	}, []string{"method", "code"})
+	// RPCDuration observes the latency of the CSI calls by method.
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of CSI calls by method.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"method"})
+	// MountOperations counts the mounts and unmounts of FUSE filesystems by mounter and result.
+	MountOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mount_operations_total",
+		Help:      "Number of FUSE mounts and unmounts by mounter, operation and result.",
+	}, []string{"mounter", "operation", "result"})
+	// S3Requests counts the S3 API calls by operation.
+	S3Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "s3_requests_total",
+		Help:      "Number of S3 API calls by operation.",
+	}, []string{"operation"})
+	// S3Errors counts the failed S3 API calls by operation.
+	S3Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "s3_errors_total",
+		Help:      "Number of failed S3 API calls by operation.",
+	}, []string{"operation"})
+	// S3Duration observes the latency of the S3 API calls by operation.
+	S3Duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "s3_request_duration_seconds",
+		Help:      "Latency of S3 API calls by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	// DeletedObjects counts the objects removed while deleting volumes.
+	DeletedObjects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deleted_objects_total",
+		Help:      "Number of objects removed while deleting volumes.",
+	})
+	// DeletedBytes counts the size of the objects removed while deleting volumes.
+	DeletedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deleted_bytes_total",
+		Help:      "Size of the objects removed while deleting volumes in bytes.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RPCRequests,
+		RPCDuration,
+		MountOperations,
+		S3Requests,
+		S3Errors,
+		S3Duration,
+		DeletedObjects,
+		DeletedBytes,
+	)
+}
+
+// ObserveS3 records an S3 API call which started at start and failed if err is not nil
+func ObserveS3(operation string, start time.Time, err error) {
+	S3Requests.WithLabelValues(operation).Inc()
+	S3Duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		S3Errors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveMount records a mount or unmount of a FUSE filesystem by the mounter
+func ObserveMount(mounter, operation string, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+	MountOperations.WithLabelValues(mounter, operation, result).Inc()
+}
+
+// activeMountsCollector reports the active FUSE mounts by mounter
+type activeMountsCollector struct {
+	desc  *prometheus.Desc
+	count func() (map[string]int, error)
+}
+
+// NewActiveMountsCollector returns a collector of the active FUSE mounts, counted by mounter on every scrape
+func NewActiveMountsCollector(count func() (map[string]int, error)) prometheus.Collector {
+	return &activeMountsCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "active_mounts"),
+			"Number of FUSE mounts served by a running process, by mounter.", []string{"mounter"}, nil),
+		count: count,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *activeMountsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *activeMountsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for mounter, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), mounter)
+	}
+}
+
+// cacheCollector reports the usage of the volume caches of the node
+type cacheCollector struct {
+	used     *prometheus.Desc
+	reserved *prometheus.Desc
+	usage    func() (used, reserved int64)
+}
+
+// NewCacheCollector returns a collector of the used and reserved bytes of the volume caches
+func NewCacheCollector(usage func() (used, reserved int64)) prometheus.Collector {
+	return &cacheCollector{
+		used: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "used_bytes"),
+			"Size of the files in the volume caches of the node.", nil, nil),
+		reserved: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "reserved_bytes"),
+			"Size reserved for the volume caches of the node.", nil, nil),
+		usage: usage,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.used
+	ch <- c.reserved
+}
+
+// Collect implements prometheus.Collector.
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	used, reserved := c.usage()
+	ch <- prometheus.MustNewConstMetric(c.used, prometheus.GaugeValue, float64(used))
+	ch <- prometheus.MustNewConstMetric(c.reserved, prometheus.GaugeValue, float64(reserved))
+}
+
+// Serve exposes the metrics at /metrics of address in the background
+func Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	klog.Infof("Serving metrics on %s", listener.Addr())
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Failed to serve metrics: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 23:31:50
+ * @file: metrics_test.go
+ * @description: metrics 单测
+ */
+
+func TestObserveS3(t *testing.T) {
+	start := time.Now()
+	ObserveS3("MakeBucket", start, nil)
+	ObserveS3("MakeBucket", start, errors.New("access denied"))
+
+	if got := testutil.ToFloat64(S3Requests.WithLabelValues("MakeBucket")); got != 2 {
+		t.Errorf("s3_requests_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(S3Errors.WithLabelValues("MakeBucket")); got != 1 {
+		t.Errorf("s3_errors_total = %v, want 1", got)
+	}
+}
+
+func TestObserveMount(t *testing.T) {
+	ObserveMount("rclone", "mount", nil)
+	ObserveMount("rclone", "mount", errors.New("timeout"))
+	ObserveMount("rclone", "unmount", nil)
+
+	for labels, want := range map[[2]string]float64{
+		{"mount", ResultSuccess}:   1,
+		{"mount", ResultFailure}:   1,
+		{"unmount", ResultSuccess}: 1,
+		{"unmount", ResultFailure}: 0,
+	} {
+		if got := testutil.ToFloat64(MountOperations.WithLabelValues("rclone", labels[0], labels[1])); got != want {
+			t.Errorf("mount_operations_total%v = %v, want %v", labels, got, want)
+		}
+	}
+}
+
+func TestActiveMountsCollector(t *testing.T) {
+	c := NewActiveMountsCollector(func() (map[string]int, error) {
+		return map[string]int{"s3fs": 2, "rclone": 1}, nil
+	})
+	want := `
+# HELP s3_csi_active_mounts Number of FUSE mounts served by a running process, by mounter.
+# TYPE s3_csi_active_mounts gauge
+s3_csi_active_mounts{mounter="rclone"} 1
+s3_csi_active_mounts{mounter="s3fs"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Errorf("CollectAndCompare() error = %v", err)
+	}
+}
+
+func TestCacheCollector(t *testing.T) {
+	c := NewCacheCollector(func() (int64, int64) { return 512, 4096 })
+	want := `
+# HELP s3_csi_cache_reserved_bytes Size reserved for the volume caches of the node.
+# TYPE s3_csi_cache_reserved_bytes gauge
+s3_csi_cache_reserved_bytes 4096
+# HELP s3_csi_cache_used_bytes Size of the files in the volume caches of the node.
+# TYPE s3_csi_cache_used_bytes gauge
+s3_csi_cache_used_bytes 512
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Errorf("CollectAndCompare() error = %v", err)
+	}
+}
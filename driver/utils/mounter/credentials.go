@@ -0,0 +1,168 @@
+package mounter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 14:02:13
+ * @file: credentials.go
+ * @description: 每个挂载点独立的凭证文件
+ */
+
+// DefaultRuntimeDir is the default driver-owned directory of the per-mount credential files
+const DefaultRuntimeDir = "/run/s3-csi"
+
+var (
+	runtimeDirMu sync.RWMutex
+	runtimeDir   = DefaultRuntimeDir
+)
+
+// SetRuntimeDir sets the directory holding the per-mount credential files
+func SetRuntimeDir(dir string) {
+	runtimeDirMu.Lock()
+	defer runtimeDirMu.Unlock()
+	runtimeDir = dir
+}
+
+// RuntimeDir returns the directory holding the per-mount credential files
+func RuntimeDir() string {
+	runtimeDirMu.RLock()
+	defer runtimeDirMu.RUnlock()
+	return runtimeDir
+}
+
+// mountID returns the identifier of the mount at target used to name its credential files
+func mountID(target string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(target)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// writeCredentialFile writes the credentials of the mount at target to a new 0600 file
+// and returns its path. The file is replaced atomically, so a concurrent reader never
+// sees partial or stale content.
+func writeCredentialFile(target, ext, content string) (string, error) {
+	dir := RuntimeDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create runtime directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+mountID(target)+"-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(dir, mountID(target)+ext)
+	if err := os.Rename(tmp.Name(), fileName); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// removeCredentialFiles securely removes all credential files of the mount at target
+func removeCredentialFiles(ctx context.Context, target string) error {
+	files, err := filepath.Glob(filepath.Join(RuntimeDir(), mountID(target)+".*"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, secureRemove(ctx, file))
+	}
+	return errors.Join(errs...)
+}
+
+// secureRemove overwrites the file with zeros before removing it
+func secureRemove(ctx context.Context, fileName string) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = f.Write(make([]byte, info.Size()))
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to overwrite credential file", "file", fileName)
+	}
+	if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GarbageCollectCredentials removes the credential files whose mounts no longer exist,
+// e.g. because the node restarted while volumes were mounted.
+func GarbageCollectCredentials(ctx context.Context) error {
+	mountPoints, err := mount.New("").List()
+	if err != nil {
+		return fmt.Errorf("failed to list mount points: %w", err)
+	}
+	active := make([]string, 0, len(mountPoints))
+	for _, mp := range mountPoints {
+		active = append(active, mp.Path)
+	}
+	return gcCredentialFiles(ctx, active)
+}
+
+// gcCredentialFiles removes the credential files which belong to none of the active mount points
+func gcCredentialFiles(ctx context.Context, active []string) error {
+	entries, err := os.ReadDir(RuntimeDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	activeIDs := make(map[string]struct{}, len(active))
+	for _, path := range active {
+		activeIDs[mountID(path)] = struct{}{}
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		id, _, _ := strings.Cut(strings.TrimPrefix(entry.Name(), ".tmp-"), ".")
+		id, _, _ = strings.Cut(id, "-")
+		if _, ok := activeIDs[id]; ok {
+			continue
+		}
+		klog.FromContext(ctx).V(2).Info("Removing stale credential file", "file", entry.Name())
+		errs = append(errs, secureRemove(ctx, filepath.Join(RuntimeDir(), entry.Name())))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package mounter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 14:30:52
+ * @file: credentials_test.go
+ * @description: credentials 单测
+ */
+
+func TestCredentialFiles(t *testing.T) {
+	SetRuntimeDir(t.TempDir())
+	defer SetRuntimeDir(DefaultRuntimeDir)
+
+	first, err := writeCredentialFile("/staging/a", ".passwd-s3fs", "AKID:A_LONGER_SECRET")
+	if err != nil {
+		t.Fatalf("writeCredentialFile() error = %v", err)
+	}
+	// rewriting with shorter content must not leave stale bytes behind
+	if _, err := writeCredentialFile("/staging/a", ".passwd-s3fs", "AKID:B"); err != nil {
+		t.Fatalf("writeCredentialFile() error = %v", err)
+	}
+	second, err := writeCredentialFile("/staging/b", ".passwd-s3fs", "AKID:C")
+	if err != nil {
+		t.Fatalf("writeCredentialFile() error = %v", err)
+	}
+	if first == second {
+		t.Fatalf("writeCredentialFile() returned the same file %s for different mounts", first)
+	}
+
+	content, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(content) != "AKID:B" {
+		t.Errorf("credential file content = %q, want %q", content, "AKID:B")
+	}
+	info, err := os.Stat(first)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("credential file mode = %o, want 600", perm)
+	}
+
+	if err := removeCredentialFiles(context.Background(), "/staging/a"); err != nil {
+		t.Fatalf("removeCredentialFiles(context.Background(), ) error = %v", err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("credential file %s still exists after removal", first)
+	}
+	if err := removeCredentialFiles(context.Background(), "/staging/a"); err != nil {
+		t.Errorf("removeCredentialFiles(context.Background(), ) of removed mount error = %v", err)
+	}
+}
+
+func TestGCCredentialFiles(t *testing.T) {
+	SetRuntimeDir(t.TempDir())
+	defer SetRuntimeDir(DefaultRuntimeDir)
+
+	active, err := writeCredentialFile("/staging/active", ".rclone.conf", "config")
+	if err != nil {
+		t.Fatalf("writeCredentialFile() error = %v", err)
+	}
+	stale, err := writeCredentialFile("/staging/stale", ".passwd-s3fs", "AKID:SECRET")
+	if err != nil {
+		t.Fatalf("writeCredentialFile() error = %v", err)
+	}
+	leftover := filepath.Join(RuntimeDir(), ".tmp-"+mountID("/staging/stale")+"-123")
+	if err := os.WriteFile(leftover, []byte("AKID:SECRET"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := gcCredentialFiles(context.Background(), []string{"/staging/active"}); err != nil {
+		t.Fatalf("gcCredentialFiles(context.Background(), ) error = %v", err)
+	}
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("credential file of active mount was removed: %v", err)
+	}
+	for _, file := range []string{stale, leftover} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("stale credential file %s was not removed", file)
+		}
+	}
+}
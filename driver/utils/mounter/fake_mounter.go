@@ -0,0 +1,100 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 15:40:16
+ * @file: fake_mounter.go
+ * @description: 用于测试的挂载器
+ */
+
+// FakeMounter is a Mounter for tests which records mounts instead of running FUSE processes.
+type FakeMounter struct {
+	mu sync.Mutex
+	// Mounts maps the mounted targets to their volume IDs
+	Mounts map[string]string
+	// Metas holds the metadata of every New call
+	Metas []*utils.Metadata
+	// MountErr, UnmountErr and CheckErr are returned by the respective methods if set
+	MountErr   error
+	UnmountErr error
+	CheckErr   error
+}
+
+// NewFakeMounter creates a new fake mounter
+func NewFakeMounter() *FakeMounter {
+	return &FakeMounter{
+		Mounts: make(map[string]string),
+	}
+}
+
+// New can be used in place of NewMounter, it returns the fake mounter itself
+func (f *FakeMounter) New(meta *utils.Metadata, _ *utils.Config) (Mounter, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Metas = append(f.Metas, meta)
+	return f, nil
+}
+
+// ForMountPoint can be used in place of ForMountPoint, it returns the fake mounter itself
+func (f *FakeMounter) ForMountPoint(_ string) Mounter {
+	return f
+}
+
+// Mount records the mount of the volume at target
+func (f *FakeMounter) Mount(_ context.Context, target, volumeID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.MountErr != nil {
+		return f.MountErr
+	}
+	f.Mounts[target] = volumeID
+	return nil
+}
+
+// Unmount removes the mount at target, unmounting an unmounted target succeeds
+func (f *FakeMounter) Unmount(_ context.Context, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.UnmountErr != nil {
+		return f.UnmountErr
+	}
+	delete(f.Mounts, target)
+	return nil
+}
+
+// Check returns CheckErr, or an error if target is not mounted
+func (f *FakeMounter) Check(target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.CheckErr != nil {
+		return f.CheckErr
+	}
+	if _, ok := f.Mounts[target]; !ok {
+		return fmt.Errorf("%s is not mounted", target)
+	}
+	return nil
+}
+
+// Stats returns empty stats for mounted targets
+func (f *FakeMounter) Stats(target string) (*Stats, error) {
+	if err := f.Check(target); err != nil {
+		return nil, err
+	}
+	return &Stats{}, nil
+}
+
+// IsMounted returns true if target is mounted
+func (f *FakeMounter) IsMounted(target string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.Mounts[target]
+	return ok
+}
@@ -0,0 +1,179 @@
+package mounter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 16:58:40
+ * @file: fuse_log.go
+ * @description: FUSE进程日志的轮转与尾部缓存
+ */
+
+// rotatingLog writes the output of a FUSE process to a size-rotated file
+// and keeps its last lines in memory, to be attached to mount errors.
+type rotatingLog struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	// tail is a ring of the last complete lines, partial holds an unterminated line
+	tail    []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+// newRotatingLog opens or creates the log file, rotating it once it exceeds maxSize bytes
+// and keeping maxFiles rotated files besides the current one.
+func newRotatingLog(path string, maxSize int64, maxFiles, tailLines int) (*rotatingLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	l := &rotatingLog{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		tail:     make([]string, max(tailLines, 1)),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write implements io.Writer
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.remember(p)
+	if l.file == nil {
+		return len(p), nil
+	}
+	if l.maxSize > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Printf writes a line of the driver itself into the log
+func (l *rotatingLog) Printf(format string, args ...interface{}) {
+	_, _ = l.Write([]byte(fmt.Sprintf("[s3-csi] "+format+"\n", args...)))
+}
+
+// Tail returns the last lines written to the log, oldest first
+func (l *rotatingLog) Tail() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var lines []string
+	if l.full {
+		lines = append(lines, l.tail[l.next:]...)
+	}
+	lines = append(lines, l.tail[:l.next]...)
+	if len(l.partial) > 0 {
+		lines = append(lines, string(l.partial))
+	}
+	return lines
+}
+
+// Close closes the log file
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Remove closes the log and removes the log file with its rotated files
+func (l *rotatingLog) Remove() error {
+	if err := l.Close(); err != nil {
+		return err
+	}
+	for i := 0; i <= l.maxFiles; i++ {
+		if err := os.Remove(l.rotatedPath(i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// remember adds the complete lines of p to the tail ring
+func (l *rotatingLog) remember(p []byte) {
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		l.tail[l.next] = string(l.partial[:i])
+		l.next = (l.next + 1) % len(l.tail)
+		if l.next == 0 {
+			l.full = true
+		}
+		l.partial = l.partial[i+1:]
+	}
+	// do not let a process without newlines grow the buffer unbounded
+	if len(l.partial) > 4096 {
+		l.partial = l.partial[len(l.partial)-4096:]
+	}
+}
+
+// open opens the current log file for appending
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts log -> log.1 -> ... -> log.<maxFiles> and reopens an empty log
+func (l *rotatingLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	for i := l.maxFiles; i > 0; i-- {
+		if err := os.Rename(l.rotatedPath(i-1), l.rotatedPath(i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if l.maxFiles == 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return l.open()
+}
+
+// rotatedPath returns the path of the i-th rotated file, 0 is the current log
+func (l *rotatingLog) rotatedPath(i int) string {
+	if i == 0 {
+		return l.path
+	}
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
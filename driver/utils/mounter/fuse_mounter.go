@@ -0,0 +1,278 @@
+package mounter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 15:04:38
+ * @file: fuse_mounter.go
+ * @description: FUSE进程的卸载、健康检查与统计
+ */
+
+var (
+	// fuseExitTimeout is how long a FUSE process may take to exit after its mount is gone
+	fuseExitTimeout = 10 * time.Second
+	// fuseKillTimeout is how long a FUSE process may take to exit after SIGTERM
+	fuseKillTimeout = 5 * time.Second
+	// mountUtils unmounts the FUSE mounts and bind mounts them again after a restart, it is replaced in tests
+	mountUtils mount.Interface = mount.New("")
+)
+
+// fuseMounter implements the process handling shared by all FUSE mounters.
+// It is embedded by the mounters, which add Mount.
+type fuseMounter struct {
+	// binary is the executable of the FUSE process, empty if unknown
+	binary string
+}
+
+// Mount is not supported without a volume context, the embedding mounters provide their own.
+func (f *fuseMounter) Mount(_ context.Context, target, volumeID string) error {
+	return status.Errorf(codes.FailedPrecondition, "Mount: cannot mount volume %s without a mounter backend", volumeID)
+}
+
+// Unmount unmounts target, stops and reaps the FUSE process serving it and removes its credentials.
+func (f *fuseMounter) Unmount(ctx context.Context, target string) (err error) {
+	if backend := backendForBinary(f.binary); backend != nil {
+		// bind mounts have no binary and are not counted
+		defer func() { metrics.ObserveMount(backend.Name, "unmount", err) }()
+	}
+	r := currentRunner()
+	st, err := r.Status(target)
+	if err != nil {
+		return fmt.Errorf("failed to look up the FUSE process of %s: %w", target, err)
+	}
+	supervised := st != nil
+	var pid int
+	if !supervised && f.binary != "" {
+		// the process was started by an earlier instance of the driver,
+		// without a known binary no FUSE process of a backend serves target
+		var err error
+		if pid, err = findFuseProcess(f.binary, target); err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to look up the FUSE process", "target", target)
+		}
+	}
+
+	notMount, err := mount.New("").IsLikelyNotMountPoint(target)
+	switch {
+	case os.IsNotExist(err), err == nil && notMount:
+		// already unmounted, only the process and the credentials may be left
+	case err == nil, mount.IsCorruptedMnt(err):
+		if err := unmountPath(ctx, target); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	if supervised {
+		if err := r.Stop(ctx, target); err != nil {
+			return fmt.Errorf("failed to stop FUSE process of %s: %w", target, err)
+		}
+	} else if pid > 0 {
+		if err := stopProcess(ctx, pid); err != nil {
+			return fmt.Errorf("failed to stop FUSE process %d of %s: %w", pid, target, err)
+		}
+	}
+	return removeCredentialFiles(ctx, target)
+}
+
+// unmountPath unmounts target. Corrupted mounts, whose FUSE process is gone, and busy mounts
+// are detached lazily, so that they disappear once the last user is gone.
+func unmountPath(ctx context.Context, target string) error {
+	err := mountUtils.Unmount(target)
+	if err == nil {
+		return nil
+	}
+	klog.FromContext(ctx).Info("Failed to unmount, detaching it lazily", "target", target, "err", err)
+	if err := syscall.Unmount(target, syscall.MNT_DETACH); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return fmt.Errorf("failed to detach %s: %w", target, err)
+	}
+	return nil
+}
+
+// Check returns an error if target is not mounted, corrupted or not served by a FUSE process.
+func (f *fuseMounter) Check(target string) error {
+	notMount, err := mount.New("").IsLikelyNotMountPoint(target)
+	if err != nil {
+		if mount.IsCorruptedMnt(err) {
+			return fmt.Errorf("mount %s is corrupted: %w", target, err)
+		}
+		return err
+	}
+	if notMount {
+		return fmt.Errorf("%s is not mounted", target)
+	}
+	st, err := currentRunner().Status(target)
+	if err != nil {
+		return fmt.Errorf("failed to look up the FUSE process of %s: %w", target, err)
+	}
+	if st != nil {
+		if st.Exited {
+			return fmt.Errorf("%s process serving %s exited with code %d", st.Command, target, st.ExitCode)
+		}
+		return nil
+	}
+	pid, err := findFuseProcess(f.binary, target)
+	if err != nil {
+		return err
+	}
+	if pid == 0 {
+		return fmt.Errorf("no %s process is serving %s", f.binary, target)
+	}
+	return nil
+}
+
+// Stats returns the I/O counters of the FUSE process serving target.
+func (f *fuseMounter) Stats(target string) (*Stats, error) {
+	r := currentRunner()
+	st, err := r.Status(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the FUSE process of %s: %w", target, err)
+	}
+	if st != nil {
+		return r.Stats(target)
+	}
+	pid, err := findFuseProcess(f.binary, target)
+	if err != nil {
+		return nil, err
+	}
+	if pid == 0 {
+		return nil, fmt.Errorf("no %s process is serving %s", f.binary, target)
+	}
+	return readProcessStats(pid)
+}
+
+// findFuseProcess returns the pid of the process of binary whose command line contains target,
+// or 0 if there is none. An empty binary matches any process.
+func findFuseProcess(binary, target string) (int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+	target = filepath.Clean(target)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		args := strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+		if binary != "" && filepath.Base(args[0]) != binary {
+			continue
+		}
+		for _, arg := range args[1:] {
+			if filepath.Clean(arg) == target {
+				return pid, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// stopProcess waits for the process to exit after its mount is gone, terminates it if it does not,
+// and reaps it if it is a child of the driver.
+func stopProcess(ctx context.Context, pid int) error {
+	if waitForProcessExit(pid, fuseExitTimeout) {
+		return nil
+	}
+	logger := klog.FromContext(ctx)
+	logger.Info("FUSE process did not exit after unmount, sending SIGTERM", "pid", pid)
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	if waitForProcessExit(pid, fuseKillTimeout) {
+		return nil
+	}
+	logger.Info("FUSE process did not exit after SIGTERM, sending SIGKILL", "pid", pid)
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	if !waitForProcessExit(pid, fuseKillTimeout) {
+		return fmt.Errorf("process %d did not exit after SIGKILL", pid)
+	}
+	return nil
+}
+
+// waitForProcessExit returns true once the process exited within the timeout.
+// Zombies are reaped, which matters when the driver runs as pid 1 of its container.
+func waitForProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		var ws syscall.WaitStatus
+		if wpid, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil); err == nil && wpid == pid {
+			return true
+		}
+		if !processAlive(pid) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// processAlive returns false if the process does not exist or is a zombie
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// the state follows the parenthesized command name, which may contain spaces
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 || i+2 >= len(stat) {
+		return false
+	}
+	return stat[i+2] != 'Z' && stat[i+2] != 'X'
+}
+
+// readProcessStats parses /proc/<pid>/io
+func readProcessStats(pid int) (*Stats, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stats := &Stats{PID: pid}
+	fields := map[string]*uint64{
+		"rchar":       &stats.ReadChars,
+		"wchar":       &stats.WriteChars,
+		"syscr":       &stats.ReadSyscalls,
+		"syscw":       &stats.WriteSyscalls,
+		"read_bytes":  &stats.ReadBytes,
+		"write_bytes": &stats.WriteBytes,
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if field, known := fields[key]; ok && known {
+			if *field, err = strconv.ParseUint(strings.TrimSpace(value), 10, 64); err != nil {
+				return nil, fmt.Errorf("failed to parse %s of process %d: %w", key, pid, err)
+			}
+		}
+	}
+	return stats, scanner.Err()
+}
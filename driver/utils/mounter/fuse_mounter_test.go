@@ -0,0 +1,61 @@
+package mounter
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 16:02:25
+ * @file: fuse_mounter_test.go
+ * @description: fuse_mounter 单测
+ */
+
+func TestReadProcessStats(t *testing.T) {
+	stats, err := readProcessStats(os.Getpid())
+	if err != nil {
+		t.Skipf("/proc/<pid>/io is not readable: %v", err)
+	}
+	if stats.PID != os.Getpid() || stats.ReadChars == 0 {
+		t.Errorf("readProcessStats() = %+v, want counters of the test process", stats)
+	}
+}
+
+func TestFindAndStopProcess(t *testing.T) {
+	target := t.TempDir()
+	// the trailing true keeps sh from exec'ing sleep, so target stays in its command line
+	cmd := exec.Command("sh", "-c", "sleep 60; true", "sh", target)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start sh: %v", err)
+	}
+
+	// the command line only changes once the child executed sh
+	var pid int
+	var err error
+	for i := 0; i < 50 && pid == 0; i++ {
+		if pid, err = findFuseProcess("sh", target); err != nil {
+			t.Fatalf("findFuseProcess() error = %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if pid != cmd.Process.Pid {
+		t.Fatalf("findFuseProcess() = %d, want %d", pid, cmd.Process.Pid)
+	}
+	if pid, _ := findFuseProcess("s3fs", target); pid != 0 {
+		t.Errorf("findFuseProcess() matched process %d of another binary", pid)
+	}
+
+	// sh does not exit by itself, shorten the wait before it is terminated
+	defer func(timeout time.Duration) { fuseExitTimeout = timeout }(fuseExitTimeout)
+	fuseExitTimeout = 100 * time.Millisecond
+	if err := stopProcess(context.Background(), pid); err != nil {
+		t.Fatalf("stopProcess() error = %v", err)
+	}
+	if processAlive(pid) {
+		t.Errorf("process %d is still alive after stopProcess()", pid)
+	}
+}
@@ -0,0 +1,75 @@
+package mounter
+
+import (
+	"context"
+	"slices"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 12:18:02
+ * @file: geesefs_mounter.go
+ * @description: geesefs挂载器
+ */
+
+// geesefsCmd is the geesefs binary
+const geesefsCmd = "geesefs"
+
+// geesefsOptionSpecs are the geesefs options volumes may set, the goofys ones and its own
+var geesefsOptionSpecs = append(slices.Clone(goofysOptionSpecs), []OptionSpec{
+	{Name: "memory-limit", Kind: OptionInt},
+	{Name: "max-flushers", Kind: OptionInt},
+	{Name: "max-parallel-parts", Kind: OptionInt},
+	{Name: "read-ahead", Kind: OptionInt},
+	{Name: "read-ahead-large", Kind: OptionInt},
+	{Name: "entry-limit", Kind: OptionInt},
+	{Name: "part-sizes", Kind: OptionString},
+}...)
+
+func init() {
+	Register(&Backend{
+		Name:             "geesefs",
+		Binary:           geesefsCmd,
+		New:              NewGeeseFSMounter,
+		TranslateOptions: goofysOptions, // geesefs is a goofys fork sharing its flag dialect
+		Options:          geesefsOptionSpecs,
+		CheckAvailable:   BinaryAvailable(geesefsCmd),
+		FSType:           "fuse.geesefs",
+	})
+}
+
+type GeeseFSMounter struct {
+	fuseMounter
+	meta *utils.Metadata
+	cfg  *utils.Config
+}
+
+// NewGeeseFSMounter creates a new geesefs mounter
+func NewGeeseFSMounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
+	return &GeeseFSMounter{
+		fuseMounter: fuseMounter{binary: geesefsCmd},
+		meta:        meta,
+		cfg:         cfg,
+	}, nil
+}
+
+// Mount mounts the bucket with geesefs, the credentials are passed through the environment
+func (g *GeeseFSMounter) Mount(ctx context.Context, target, volumeID string) error {
+	return FuseMount(ctx, target, geesefsCmd, g.args(target), awsCredentialEnvs(g.cfg))
+}
+
+// args returns the geesefs arguments for mounting the volume at target
+func (g *GeeseFSMounter) args(target string) []string {
+	args := []string{
+		"-f",
+		"--endpoint", g.cfg.Endpoint,
+		"-o", "allow_other",
+	}
+	if g.cfg.Region != "" {
+		args = append(args, "--region", g.cfg.Region)
+	}
+	args = append(args, goofysOptions(g.meta)...)
+	return append(args, bucketWithPrefix(g.meta), target)
+}
@@ -0,0 +1,59 @@
+package mounter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 12:40:09
+ * @file: geesefs_mounter_test.go
+ * @description: geesefs_mounter 单测
+ */
+
+func TestGeeseFSMounterArgs(t *testing.T) {
+	uid := uint32(1000)
+	tests := []struct {
+		name string
+		meta *utils.Metadata
+		cfg  *utils.Config
+		want []string
+	}{
+		{
+			name: "Test bucket with region",
+			meta: &utils.Metadata{BucketName: "bucket"},
+			cfg:  &utils.Config{Endpoint: "http://minio:9000", Region: "us-east-1"},
+			want: []string{"-f", "--endpoint", "http://minio:9000", "-o", "allow_other", "--region", "us-east-1", "bucket", "/target"},
+		},
+		{
+			name: "Test prefix, mount permissions, uid and cache",
+			meta: &utils.Metadata{
+				BucketName:       "bucket",
+				Prefix:           "datasets",
+				MountPermissions: 0775,
+				UID:              &uid,
+				CacheDir:         "/var/cache/geesefs",
+				MountOptions:     []string{"--memory-limit", "4000"},
+			},
+			cfg: &utils.Config{Endpoint: "http://minio:9000"},
+			want: []string{"-f", "--endpoint", "http://minio:9000", "-o", "allow_other",
+				"--uid", "1000", "--dir-mode", "0775", "--file-mode", "0664",
+				"--cache", "/var/cache/geesefs", "--memory-limit", "4000", "bucket:datasets", "/target"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewGeeseFSMounter(tt.meta, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewGeeseFSMounter() error = %v", err)
+			}
+			if got := m.(*GeeseFSMounter).args("/target"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 12:05:36
+ * @file: goofys_mounter.go
+ * @description: goofys挂载器
+ */
+
+// goofysCmd is the goofys binary
+const goofysCmd = "goofys"
+
+// goofysOptionSpecs are the goofys options volumes may set
+var goofysOptionSpecs = []OptionSpec{
+	{Name: "cheap", Kind: OptionFlag},
+	{Name: "no-implicit-dir", Kind: OptionFlag},
+	{Name: "sse", Kind: OptionFlag},
+	{Name: "debug_s3", Kind: OptionFlag},
+	{Name: "debug_fuse", Kind: OptionFlag},
+	{Name: "stat-cache-ttl", Kind: OptionDuration},
+	{Name: "type-cache-ttl", Kind: OptionDuration},
+	{Name: "http-timeout", Kind: OptionDuration},
+	{Name: "storage-class", Kind: OptionEnum, Values: storageClasses},
+	{Name: "acl", Kind: OptionEnum, Values: cannedACLs},
+}
+
+func init() {
+	Register(&Backend{
+		Name:             "goofys",
+		Binary:           goofysCmd,
+		New:              NewGoofysMounter,
+		TranslateOptions: goofysOptions,
+		Options:          goofysOptionSpecs,
+		CheckAvailable:   BinaryAvailable(goofysCmd),
+		FSType:           "fuse.goofys",
+	})
+}
+
+type GoofysMounter struct {
+	fuseMounter
+	meta *utils.Metadata
+	cfg  *utils.Config
+}
+
+// NewGoofysMounter creates a new goofys mounter
+func NewGoofysMounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
+	return &GoofysMounter{
+		fuseMounter: fuseMounter{binary: goofysCmd},
+		meta:        meta,
+		cfg:         cfg,
+	}, nil
+}
+
+// Mount mounts the bucket with goofys, the credentials are passed through the environment
+func (g *GoofysMounter) Mount(ctx context.Context, target, volumeID string) error {
+	return FuseMount(ctx, target, goofysCmd, g.args(target), awsCredentialEnvs(g.cfg))
+}
+
+// args returns the goofys arguments for mounting the volume at target
+func (g *GoofysMounter) args(target string) []string {
+	args := []string{
+		"-f",
+		"--endpoint", g.cfg.Endpoint,
+		"-o", "allow_other",
+	}
+	if g.cfg.Region != "" {
+		args = append(args, "--region", g.cfg.Region)
+	}
+	args = append(args, goofysOptions(g.meta)...)
+	return append(args, bucketWithPrefix(g.meta), target)
+}
+
+// goofysOptions translates the generic volume options into goofys arguments.
+func goofysOptions(meta *utils.Metadata) []string {
+	var args []string
+	if meta.ReadOnly {
+		args = append(args, "-o", "ro")
+	}
+	if meta.UID != nil {
+		args = append(args, "--uid", fmt.Sprintf("%d", *meta.UID))
+	}
+	if meta.GID != nil {
+		args = append(args, "--gid", fmt.Sprintf("%d", *meta.GID))
+	}
+	if dirMode, fileMode, ok := fileModes(meta); ok {
+		args = append(args, "--dir-mode", fmt.Sprintf("0%o", dirMode), "--file-mode", fmt.Sprintf("0%o", fileMode))
+	}
+	if meta.CacheDir != "" {
+		args = append(args, "--cache", meta.CacheDir)
+	}
+	args = append(args, meta.MountFlags...)
+	return append(args, meta.MountOptions...)
+}
@@ -0,0 +1,68 @@
+package mounter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 12:31:44
+ * @file: goofys_mounter_test.go
+ * @description: goofys_mounter 单测
+ */
+
+func TestGoofysMounterArgs(t *testing.T) {
+	uid, gid, umask := uint32(1000), uint32(2000), uint32(0027)
+	tests := []struct {
+		name string
+		meta *utils.Metadata
+		cfg  *utils.Config
+		want []string
+	}{
+		{
+			name: "Test bucket without options",
+			meta: &utils.Metadata{BucketName: "bucket"},
+			cfg:  &utils.Config{Endpoint: "https://s3.example.com"},
+			want: []string{"-f", "--endpoint", "https://s3.example.com", "-o", "allow_other", "bucket", "/target"},
+		},
+		{
+			name: "Test prefix, region, ownership and cache",
+			meta: &utils.Metadata{
+				BucketName: "bucket",
+				Prefix:     "pvc-1",
+				UID:        &uid,
+				GID:        &gid,
+				Umask:      &umask,
+				CacheDir:   "/var/cache/s3",
+				MountFlags: []string{"--cheap"},
+			},
+			cfg: &utils.Config{Endpoint: "https://s3.example.com", Region: "eu-west-1"},
+			want: []string{"-f", "--endpoint", "https://s3.example.com", "-o", "allow_other", "--region", "eu-west-1",
+				"--uid", "1000", "--gid", "2000", "--dir-mode", "0750", "--file-mode", "0640",
+				"--cache", "/var/cache/s3", "--cheap", "bucket:pvc-1", "/target"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewGoofysMounter(tt.meta, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewGoofysMounter() error = %v", err)
+			}
+			if got := m.(*GoofysMounter).args("/target"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAWSCredentialEnvs(t *testing.T) {
+	got := awsCredentialEnvs(&utils.Config{AccessKeyID: "AKID", SecretAccessKey: "SECRET"})
+	want := []string{"AWS_ACCESS_KEY_ID=AKID", "AWS_SECRET_ACCESS_KEY=SECRET"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("awsCredentialEnvs() = %v, want %v", got, want)
+	}
+}
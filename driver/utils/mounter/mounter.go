@@ -1,15 +1,17 @@
 package mounter
 
 import (
-	"os"
-	"os/exec"
-	"time"
+	"context"
+	"errors"
+	"strings"
 
 	"github.com/keington/s3-csi-driver/driver/utils"
+	"github.com/keington/s3-csi-driver/driver/utils/metrics"
+	"github.com/keington/s3-csi-driver/driver/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/klog/v2"
-	mount "k8s.io/mount-utils"
 )
 
 /**
@@ -22,64 +24,165 @@ import (
 // Mounter interface which can be implemented
 // by the different mounter types
 type Mounter interface {
-	Mount(target, volumeID string) error
+	// Mount mounts the volume at target, giving up when ctx is done.
+	Mount(ctx context.Context, target, volumeID string) error
+	// Unmount unmounts target, stops the FUSE process serving it and removes its credentials.
+	Unmount(ctx context.Context, target string) error
+	// Check returns an error if the mount at target is not alive.
+	Check(target string) error
+	// Stats returns the I/O counters of the FUSE process serving target.
+	Stats(target string) (*Stats, error)
 }
 
-// NewMounter creates a new mounter
+// Stats holds the process-level I/O counters of a FUSE process, read from /proc/<pid>/io
+type Stats struct {
+	PID           int    `json:"PID"`
+	ReadChars     uint64 `json:"ReadChars"`
+	WriteChars    uint64 `json:"WriteChars"`
+	ReadSyscalls  uint64 `json:"ReadSyscalls"`
+	WriteSyscalls uint64 `json:"WriteSyscalls"`
+	ReadBytes     uint64 `json:"ReadBytes"`
+	WriteBytes    uint64 `json:"WriteBytes"`
+}
+
+// NewMounter creates a new mounter of the backend selected by the volume
 func NewMounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
-	mounterType := meta.Mounter
-	if len(mounterType) == 0 {
-		mounterType = cfg.Mounter
+	mounterType := BackendName(meta, cfg)
+	backend, ok := Lookup(mounterType)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Mounter %s not supported, available mounters: %v", mounterType, Backends())
 	}
-	switch mounterType {
-	case "s3fs":
-		return NewS3Mounter(meta, cfg)
-	default:
-		return nil, status.Errorf(codes.InvalidArgument, "Mounter %s not supported", mounterType)
+	if err := backend.CheckAvailable(); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Mounter %s is not available on this node: %v", mounterType, err)
 	}
+	return backend.New(meta, cfg)
 }
 
-// Unmount unmounts the volume
-func Unmount(path string) error {
-	if err := mount.New("").Unmount(path); err != nil {
-		return err
+// BackendName returns the name of the backend selected by the volume, the secrets or the default.
+func BackendName(meta *utils.Metadata, cfg *utils.Config) string {
+	if len(meta.Mounter) > 0 {
+		return meta.Mounter
 	}
-	return nil
+	if len(cfg.Mounter) > 0 {
+		return cfg.Mounter
+	}
+	return defaultMounterName()
 }
 
-// FuseMount mounts the fuse
-func FuseMount(path string, command string, args []string, envs []string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stderr = os.Stderr
-	// cmd.Environ() returns envs inherited from the current process
-	cmd.Env = append(cmd.Environ(), envs...)
-	klog.V(3).Infof("Mounting fuse with command: %s and args: %s", command, args)
+// ForMountPoint returns the mounter of the backend whose FUSE process serves target.
+// It is used to tear down mounts created before the driver restarted, without their volume context.
+func ForMountPoint(target string) Mounter {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	if st, err := currentRunner().Status(target); err != nil {
+		klog.Warningf("failed to look up the FUSE process of %s: %v", target, err)
+	} else if st != nil {
+		for _, backend := range backends {
+			if backend.Binary == st.Command {
+				return &fuseMounter{binary: backend.Binary}
+			}
+		}
+	}
+	for _, backend := range backends {
+		if pid, _ := findFuseProcess(backend.Binary, target); pid > 0 {
+			return &fuseMounter{binary: backend.Binary}
+		}
+	}
+	return &fuseMounter{}
+}
 
-	out, err := cmd.Output()
+// ActiveMounts counts the mounts served by a running FUSE process, by backend name.
+func ActiveMounts() (map[string]int, error) {
+	processes, err := ListProcesses(context.Background())
 	if err != nil {
-		return status.Errorf(codes.Internal, "Mount: failed to mount %s: %v", path, out)
+		return nil, err
 	}
-
-	return waitForMount(path, 10*time.Second)
+	counts := make(map[string]int)
+	for _, p := range processes {
+		if p.Exited {
+			continue
+		}
+		if backend := backendForBinary(p.Command); backend != nil {
+			counts[backend.Name]++
+		}
+	}
+	return counts, nil
 }
 
-// waitForMount waits for the mount to be ready
-// before returning
-func waitForMount(path string, timeout time.Duration) error {
-	var elapsed time.Duration
-	var interval = 10 * time.Millisecond
-	for {
-		notMount, err := mount.New("").IsLikelyNotMountPoint(path)
-		if err != nil {
-			return err
+// FuseMount mounts the fuse. The command must run in the foreground, it is supervised
+// and its output is captured in the per-mount log. It waits for the mount within the
+// mount timeout of the backend and the deadline of ctx, the process is stopped if it fails.
+func FuseMount(ctx context.Context, path string, command string, args []string, envs []string) (err error) {
+	logger := klog.FromContext(ctx)
+	logger.V(3).Info("Mounting FUSE filesystem", "command", command, "args", args)
+	name, fsType, timeout := command, "", mountTimeout("")
+	if backend := backendForBinary(command); backend != nil {
+		name, fsType, timeout = backend.Name, backend.FSType, mountTimeout(backend.Name)
+	}
+	ctx, span := tracing.Start(ctx, "FuseMount", attribute.String("mounter", name), attribute.String("target", path))
+	defer func() {
+		metrics.ObserveMount(name, "mount", err)
+		tracing.End(span, err)
+	}()
+
+	r := currentRunner()
+	if err := r.Start(ctx, path, command, args, envs); err != nil {
+		return status.Errorf(codes.Internal, "Mount: failed to mount %s: %v", path, err)
+	}
+
+	// a shutdown of the driver stops the wait, the process is stopped and the mount retried after the restart
+	ctx, stopWait := context.WithCancelCause(ctx)
+	defer stopWait(nil)
+	stopOnShutdown := context.AfterFunc(utils.ShutdownContext(), func() { stopWait(utils.ErrShuttingDown) })
+	defer stopOnShutdown()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	waitCtx, waitSpan := tracing.Start(ctx, "waitForMount", attribute.String("timeout", timeout.String()))
+	err = waitForMount(waitCtx, r, path, fsType)
+	tracing.End(waitSpan, err)
+	if err != nil {
+		tail, tailErr := r.Tail(path)
+		if tailErr != nil {
+			logger.Info("Failed to read the output of the FUSE process", "command", command, "target", path, "err", tailErr)
 		}
-		if !notMount {
-			return nil
+		// the wait may have ended with ctx, the process is stopped anyway
+		if stopErr := r.Stop(context.WithoutCancel(ctx), path); stopErr != nil {
+			logger.Error(stopErr, "Failed to stop the FUSE process after failed mount", "command", command, "target", path)
 		}
-		time.Sleep(interval)
-		elapsed = elapsed + interval
-		if elapsed >= timeout {
-			return status.Errorf(codes.Internal, "Mount: failed to mount %s: timeout", path)
+		code := codes.Internal
+		if errors.Is(err, utils.ErrShuttingDown) {
+			code = codes.Unavailable
 		}
+		return status.Errorf(code, "Mount: failed to mount %s: %v, %s output:\n%s", path, err, command, strings.Join(tail, "\n"))
+	}
+	return nil
+}
+
+// awsCredentialEnvs returns the credentials of the config as the AWS environment variables
+// understood by most S3 FUSE tools, keeping them off the command line.
+func awsCredentialEnvs(cfg *utils.Config) []string {
+	return []string{
+		"AWS_ACCESS_KEY_ID=" + cfg.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + cfg.SecretAccessKey,
+	}
+}
+
+// bucketWithPrefix returns the bucket in the bucket[:prefix] notation of goofys like tools
+func bucketWithPrefix(meta *utils.Metadata) string {
+	if meta.Prefix == "" {
+		return meta.BucketName
+	}
+	return meta.BucketName + ":" + meta.Prefix
+}
+
+// fileModes returns the directory and file modes for mounters without umask support.
+// The directory mode falls back to the mount permissions when no umask is set.
+func fileModes(meta *utils.Metadata) (dirMode, fileMode uint32, ok bool) {
+	if meta.Umask != nil {
+		return 0777 &^ *meta.Umask, 0666 &^ *meta.Umask, true
+	}
+	if meta.MountPermissions != 0 {
+		return uint32(meta.MountPermissions), uint32(meta.MountPermissions) &^ 0111, true
 	}
+	return 0, 0, false
 }
@@ -0,0 +1,150 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 13:10:27
+ * @file: mountpoint_mounter.go
+ * @description: mountpoint-s3挂载器
+ */
+
+// mountpointCmd is the mountpoint-s3 binary
+const mountpointCmd = "mount-s3"
+
+// mountpointOptionSpecs are the mountpoint-s3 options volumes may set
+var mountpointOptionSpecs = []OptionSpec{
+	{Name: "read-only", Kind: OptionFlag},
+	{Name: "auto-unmount", Kind: OptionFlag},
+	{Name: "incremental-upload", Kind: OptionFlag},
+	{Name: "requester-pays", Kind: OptionFlag},
+	{Name: "debug", Kind: OptionFlag},
+	{Name: "debug-crt", Kind: OptionFlag},
+	{Name: "no-log", Kind: OptionFlag},
+	{Name: "max-threads", Kind: OptionInt},
+	{Name: "part-size", Kind: OptionInt},
+	{Name: "read-part-size", Kind: OptionInt},
+	{Name: "write-part-size", Kind: OptionInt},
+	{Name: "maximum-throughput-gbps", Kind: OptionInt},
+	{Name: "metadata-ttl", Kind: OptionString},
+	{Name: "negative-metadata-ttl", Kind: OptionString},
+	{Name: "expected-bucket-owner", Kind: OptionString},
+	{Name: "sse-kms-key-id", Kind: OptionString},
+	{Name: "storage-class", Kind: OptionEnum, Values: storageClasses},
+	{Name: "sse", Kind: OptionEnum, Values: []string{"aws:kms", "aws:kms:dsse", "AES256"}},
+	{Name: "upload-checksums", Kind: OptionEnum, Values: []string{"crc32c", "off"}},
+}
+
+func init() {
+	Register(&Backend{
+		Name:               "mountpoint-s3",
+		Binary:             mountpointCmd,
+		New:                NewMountpointMounter,
+		TranslateOptions:   mountpointOptions,
+		Options:            mountpointOptionSpecs,
+		CheckAvailable:     BinaryAvailable(mountpointCmd),
+		LimitsCache:        true,
+		ValidateCapability: validateMountpointCapability,
+	})
+}
+
+// MountpointMounter mounts buckets with mountpoint-s3. mountpoint-s3 only supports
+// sequential writes of new objects, so renames, random writes and appends fail.
+type MountpointMounter struct {
+	fuseMounter
+	meta *utils.Metadata
+	cfg  *utils.Config
+}
+
+// NewMountpointMounter creates a new mountpoint-s3 mounter
+func NewMountpointMounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
+	return &MountpointMounter{
+		fuseMounter: fuseMounter{binary: mountpointCmd},
+		meta:        meta,
+		cfg:         cfg,
+	}, nil
+}
+
+// Mount mounts the bucket with mountpoint-s3, the credentials are passed through the environment
+func (m *MountpointMounter) Mount(ctx context.Context, target, volumeID string) error {
+	return FuseMount(ctx, target, mountpointCmd, m.args(target), awsCredentialEnvs(m.cfg))
+}
+
+// args returns the mountpoint-s3 arguments for mounting the volume at target
+func (m *MountpointMounter) args(target string) []string {
+	args := []string{
+		m.meta.BucketName,
+		target,
+		"-f",
+		"--allow-other",
+	}
+	if m.meta.Prefix != "" {
+		// mountpoint-s3 requires the prefix to end with a slash
+		args = append(args, "--prefix", strings.TrimSuffix(m.meta.Prefix, "/")+"/")
+	}
+	if m.cfg.Region != "" {
+		args = append(args, "--region", m.cfg.Region)
+	}
+	if m.cfg.Endpoint != "" {
+		args = append(args, "--endpoint-url", m.cfg.Endpoint, "--force-path-style")
+	}
+	return append(args, mountpointOptions(m.meta)...)
+}
+
+// mountpointOptions translates the generic volume options into mountpoint-s3 arguments.
+// Mount flags are passed as long options, e.g. read_only becomes --read-only.
+func mountpointOptions(meta *utils.Metadata) []string {
+	var args []string
+	if meta.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	if meta.AllowDelete {
+		args = append(args, "--allow-delete")
+	}
+	if meta.AllowOverwrite {
+		args = append(args, "--allow-overwrite")
+	}
+	if meta.UID != nil {
+		args = append(args, "--uid", fmt.Sprintf("%d", *meta.UID))
+	}
+	if meta.GID != nil {
+		args = append(args, "--gid", fmt.Sprintf("%d", *meta.GID))
+	}
+	if dirMode, fileMode, ok := fileModes(meta); ok {
+		args = append(args, "--dir-mode", fmt.Sprintf("0%o", dirMode), "--file-mode", fmt.Sprintf("0%o", fileMode))
+	}
+	if meta.CacheDir != "" {
+		args = append(args, "--cache", meta.CacheDir)
+		if meta.CacheSize > 0 {
+			// mount-s3 takes the size in MiB
+			args = append(args, "--max-cache-size", fmt.Sprintf("%d", max(meta.CacheSize>>20, 1)))
+		}
+	}
+	args = append(args, meta.MountFlags...)
+	return append(args, meta.MountOptions...)
+}
+
+// validateMountpointCapability rejects access modes with multiple writers, since mountpoint-s3
+// cannot coordinate writes to the same object from different mounts.
+func validateMountpointCapability(ctx context.Context, capability *csi.VolumeCapability) error {
+	if capability.GetBlock() != nil {
+		return fmt.Errorf("mountpoint-s3 does not support block volumes")
+	}
+	switch mode := capability.GetAccessMode().GetMode(); mode {
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:
+		return fmt.Errorf("mountpoint-s3 does not support access mode %s: it cannot handle multiple writers", mode)
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER:
+		klog.FromContext(ctx).Info("Readers on other nodes do not see objects of mountpoint-s3 until they are completely written", "accessMode", mode)
+	}
+	return nil
+}
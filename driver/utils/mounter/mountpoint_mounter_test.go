@@ -0,0 +1,92 @@
+package mounter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 13:32:50
+ * @file: mountpoint_mounter_test.go
+ * @description: mountpoint_mounter 单测
+ */
+
+func TestMountpointMounterArgs(t *testing.T) {
+	gid := uint32(2000)
+	tests := []struct {
+		name string
+		meta *utils.Metadata
+		cfg  *utils.Config
+		want []string
+	}{
+		{
+			name: "Test bucket only",
+			meta: &utils.Metadata{BucketName: "bucket"},
+			cfg:  &utils.Config{},
+			want: []string{"bucket", "/target", "-f", "--allow-other"},
+		},
+		{
+			name: "Test prefix, endpoint and write options",
+			meta: &utils.Metadata{
+				BucketName:     "bucket",
+				Prefix:         "pvc-1",
+				AllowDelete:    true,
+				AllowOverwrite: true,
+				GID:            &gid,
+				CacheDir:       "/var/cache/mountpoint",
+				MountFlags:     []string{"--read-only"},
+			},
+			cfg: &utils.Config{Endpoint: "http://minio:9000", Region: "us-east-1"},
+			want: []string{"bucket", "/target", "-f", "--allow-other", "--prefix", "pvc-1/",
+				"--region", "us-east-1", "--endpoint-url", "http://minio:9000", "--force-path-style",
+				"--allow-delete", "--allow-overwrite", "--gid", "2000",
+				"--cache", "/var/cache/mountpoint", "--read-only"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMountpointMounter(tt.meta, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewMountpointMounter() error = %v", err)
+			}
+			if got := m.(*MountpointMounter).args("/target"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMountpointCapability(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    csi.VolumeCapability_AccessMode_Mode
+		wantErr bool
+	}{
+		{name: "Test single node writer", mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		{name: "Test multi node reader", mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+		{name: "Test multi node single writer", mode: csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER},
+		{name: "Test multi node multi writer", mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capability := &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: tt.mode},
+			}
+			if err := ValidateCapability(context.Background(), "mountpoint-s3", capability); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCapability() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := ValidateCapability(context.Background(), "s3fs", capability); err != nil {
+				t.Errorf("ValidateCapability() s3fs error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package mounter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 21:04:52
+ * @file: options.go
+ * @description: 挂载选项的类型化校验与管理员允许/禁止列表
+ */
+
+// OptionKind is the type of the value of a mount option
+type OptionKind int
+
+const (
+	// OptionFlag takes no value
+	OptionFlag OptionKind = iota
+	// OptionBool takes true or false
+	OptionBool
+	// OptionInt takes a non-negative integer
+	OptionInt
+	// OptionDuration takes a Go duration like 10s
+	OptionDuration
+	// OptionSize takes a size like 16M or 1Gi
+	OptionSize
+	// OptionString takes a value without whitespace, commas or quotes
+	OptionString
+	// OptionEnum takes one of the values of the option
+	OptionEnum
+)
+
+// OptionSpec describes a mount option a backend accepts in the options of a volume
+type OptionSpec struct {
+	// Name is the option without leading dashes.
+	Name string
+	// Kind is the type of the value.
+	Kind OptionKind
+	// Values are the valid values of an OptionEnum.
+	Values []string
+	// Fuse options are passed as -o name[=value], the others as --name[=value].
+	Fuse bool
+}
+
+var (
+	// sizeRegexp matches sizes with an optional binary or decimal unit
+	sizeRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([KMGTP]i?)?B?$`)
+	// optionValueRegexp matches string values which cannot smuggle further options
+	optionValueRegexp = regexp.MustCompile(`^[^\s,"'\\]+$`)
+)
+
+var (
+	// storageClasses are the S3 storage classes
+	storageClasses = []string{"STANDARD", "STANDARD_IA", "ONEZONE_IA", "REDUCED_REDUNDANCY", "INTELLIGENT_TIERING", "GLACIER", "GLACIER_IR", "DEEP_ARCHIVE"}
+	// cannedACLs are the S3 canned ACLs
+	cannedACLs = []string{"private", "public-read", "public-read-write", "authenticated-read", "bucket-owner-read", "bucket-owner-full-control"}
+)
+
+var (
+	optionPolicyMu sync.RWMutex
+	allowedOptions []string
+	deniedOptions  []string
+)
+
+// SetOptionPolicy sets the options the administrator allows and denies. Entries are
+// option names, applying to every backend, or backend:name. An empty allow-list allows
+// every option of the schema of the backend, the deny-list takes precedence.
+func SetOptionPolicy(allow, deny []string) {
+	optionPolicyMu.Lock()
+	defer optionPolicyMu.Unlock()
+	allowedOptions, deniedOptions = allow, deny
+}
+
+// optionPermitted applies the allow- and deny-list to the option of the backend
+func optionPermitted(backend, name string) bool {
+	optionPolicyMu.RLock()
+	defer optionPolicyMu.RUnlock()
+	matches := func(list []string) bool {
+		return slices.Contains(list, name) || slices.Contains(list, backend+":"+name)
+	}
+	if matches(deniedOptions) {
+		return false
+	}
+	return len(allowedOptions) == 0 || matches(allowedOptions)
+}
+
+// ParseOptions validates the mount options of a volume against the schema of the backend
+// and the option policy, and returns them as arguments of the backend. Options are given
+// as -o name[=value][,name[=value]] for FUSE options and --name[=value] or --name value.
+func ParseOptions(mounterType string, options []string) ([]string, error) {
+	if mounterType == "" {
+		mounterType = defaultMounterName()
+	}
+	backend, ok := Lookup(mounterType)
+	if !ok {
+		return nil, fmt.Errorf("mounter %s not supported, available mounters: %v", mounterType, Backends())
+	}
+
+	args := make([]string, 0, len(options))
+	for i := 0; i < len(options); i++ {
+		opt := options[i]
+		switch {
+		case strings.HasPrefix(opt, "-o"):
+			list := strings.TrimPrefix(opt, "-o")
+			if list == "" {
+				if i+1 == len(options) {
+					return nil, fmt.Errorf("option -o requires a value")
+				}
+				i++
+				list = options[i]
+			}
+			for _, item := range strings.Split(list, ",") {
+				name, value, hasValue := strings.Cut(item, "=")
+				if err := checkOption(backend, name, value, hasValue, true); err != nil {
+					return nil, err
+				}
+				args = append(args, "-o", item)
+			}
+		case strings.HasPrefix(opt, "--"):
+			name, value, hasValue := strings.Cut(strings.TrimPrefix(opt, "--"), "=")
+			spec := findOption(backend, name, false)
+			if spec != nil && spec.Kind != OptionFlag && !hasValue && i+1 < len(options) {
+				i++
+				value, hasValue = options[i], true
+			}
+			if err := checkOption(backend, name, value, hasValue, false); err != nil {
+				return nil, err
+			}
+			if hasValue {
+				args = append(args, "--"+name+"="+value)
+			} else {
+				args = append(args, "--"+name)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected mount option %q, options must start with -o or --", opt)
+		}
+	}
+	return args, nil
+}
+
+// ParseMountFlags validates the mount flags of a volume capability, given as name[=value], against
+// the schema of the backend and the option policy, and returns them as arguments of the backend.
+// A flag is a FUSE option of the backend, or else one of its options with dashes for underscores.
+func ParseMountFlags(mounterType string, flags []string) ([]string, error) {
+	if mounterType == "" {
+		mounterType = defaultMounterName()
+	}
+	backend, ok := Lookup(mounterType)
+	if !ok {
+		return nil, fmt.Errorf("mounter %s not supported, available mounters: %v", mounterType, Backends())
+	}
+
+	args := make([]string, 0, 2*len(flags))
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+		if findOption(backend, name, true) == nil {
+			if long := strings.ReplaceAll(name, "_", "-"); findOption(backend, long, false) != nil {
+				if err := checkOption(backend, long, value, hasValue, false); err != nil {
+					return nil, err
+				}
+				if hasValue {
+					args = append(args, "--"+long+"="+value)
+				} else {
+					args = append(args, "--"+long)
+				}
+				continue
+			}
+		}
+		if err := checkOption(backend, name, value, hasValue, true); err != nil {
+			return nil, err
+		}
+		args = append(args, "-o", flag)
+	}
+	return args, nil
+}
+
+// findOption returns the spec of the option of the backend, nil if the backend does not accept it
+func findOption(backend *Backend, name string, fuse bool) *OptionSpec {
+	for i := range backend.Options {
+		if backend.Options[i].Name == name && backend.Options[i].Fuse == fuse {
+			return &backend.Options[i]
+		}
+	}
+	return nil
+}
+
+// checkOption validates an option and its value
+func checkOption(backend *Backend, name, value string, hasValue, fuse bool) error {
+	display := "--" + name
+	if fuse {
+		display = "-o " + name
+	}
+	spec := findOption(backend, name, fuse)
+	if spec == nil {
+		return fmt.Errorf("mount option %s is not supported by mounter %s", display, backend.Name)
+	}
+	if !optionPermitted(backend.Name, name) {
+		return fmt.Errorf("mount option %s is not allowed by the administrator", display)
+	}
+
+	if spec.Kind == OptionFlag {
+		if hasValue {
+			return fmt.Errorf("mount option %s takes no value", display)
+		}
+		return nil
+	}
+	if !hasValue {
+		return fmt.Errorf("mount option %s requires a value", display)
+	}
+	var err error
+	switch spec.Kind {
+	case OptionBool:
+		_, err = strconv.ParseBool(value)
+	case OptionInt:
+		_, err = strconv.ParseUint(value, 10, 64)
+	case OptionDuration:
+		_, err = time.ParseDuration(value)
+	case OptionSize:
+		if !sizeRegexp.MatchString(value) {
+			err = fmt.Errorf("not a size")
+		}
+	case OptionString:
+		if !optionValueRegexp.MatchString(value) {
+			err = fmt.Errorf("must not contain whitespace, commas, quotes or backslashes")
+		}
+	case OptionEnum:
+		if !slices.Contains(spec.Values, value) {
+			err = fmt.Errorf("must be one of %v", spec.Values)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q of mount option %s: %v", value, display, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package mounter
+
+import (
+	"reflect"
+	"testing"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 21:32:40
+ * @file: options_test.go
+ * @description: options 单测
+ */
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		mounter string
+		options []string
+		allow   []string
+		deny    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "Test s3fs fuse options",
+			mounter: "s3fs",
+			options: []string{"-o", "use_path_request_style,multipart_size=64", "-odbglevel=info"},
+			want:    []string{"-o", "use_path_request_style", "-o", "multipart_size=64", "-o", "dbglevel=info"},
+		},
+		{
+			name:    "Test default mounter",
+			options: []string{"-o", "sigv4"},
+			want:    []string{"-o", "sigv4"},
+		},
+		{
+			name:    "Test rclone long options with separate and inline values",
+			mounter: "rclone",
+			options: []string{"--dir-cache-time", "5m", "--transfers=8", "--no-modtime"},
+			want:    []string{"--dir-cache-time=5m", "--transfers=8", "--no-modtime"},
+		},
+		{
+			name:    "Test passwd_file injection",
+			mounter: "s3fs",
+			options: []string{"-o", "passwd_file=/etc/shadow"},
+			wantErr: true,
+		},
+		{
+			name:    "Test option smuggled into a value",
+			mounter: "mountpoint-s3",
+			options: []string{"--metadata-ttl", "60 --endpoint-url=http://evil"},
+			wantErr: true,
+		},
+		{
+			name:    "Test option of another mounter",
+			mounter: "goofys",
+			options: []string{"--vfs-cache-max-age=1h"},
+			wantErr: true,
+		},
+		{
+			name:    "Test invalid integer",
+			mounter: "s3fs",
+			options: []string{"-o", "retries=many"},
+			wantErr: true,
+		},
+		{
+			name:    "Test invalid enum value",
+			mounter: "goofys",
+			options: []string{"--storage-class=COLD"},
+			wantErr: true,
+		},
+		{
+			name:    "Test flag with value",
+			mounter: "s3fs",
+			options: []string{"-o", "sigv4=true"},
+			wantErr: true,
+		},
+		{
+			name:    "Test positional argument",
+			mounter: "s3fs",
+			options: []string{"/etc"},
+			wantErr: true,
+		},
+		{
+			name:    "Test denied option",
+			mounter: "s3fs",
+			options: []string{"-o", "nonempty"},
+			deny:    []string{"s3fs:nonempty"},
+			wantErr: true,
+		},
+		{
+			name:    "Test option outside the allow-list",
+			mounter: "rclone",
+			options: []string{"--transfers=8"},
+			allow:   []string{"dir-cache-time"},
+			wantErr: true,
+		},
+		{
+			name:    "Test option in the allow-list",
+			mounter: "rclone",
+			options: []string{"--dir-cache-time=1m"},
+			allow:   []string{"rclone:dir-cache-time"},
+			want:    []string{"--dir-cache-time=1m"},
+		},
+		{
+			name:    "Test unknown mounter",
+			mounter: "unknown",
+			wantErr: true,
+		},
+	}
+
+	defer SetOptionPolicy(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetOptionPolicy(tt.allow, tt.deny)
+			got, err := ParseOptions(tt.mounter, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
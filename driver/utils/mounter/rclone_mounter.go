@@ -0,0 +1,175 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 11:20:05
+ * @file: rclone_mounter.go
+ * @description: rclone挂载器
+ */
+
+const (
+	// rcloneCmd is the rclone binary
+	rcloneCmd = "rclone"
+	// rcloneRemote is the name of the remote in the generated rclone config
+	rcloneRemote = "s3"
+	// defaultVFSCacheMode is required for files to be written like on a local filesystem
+	defaultVFSCacheMode = "writes"
+	// defaultRcloneProvider works with any S3 compatible endpoint
+	defaultRcloneProvider = "Other"
+)
+
+// rcloneVFSCacheModes are the valid values of --vfs-cache-mode
+var rcloneVFSCacheModes = []string{"off", "minimal", "writes", "full"}
+
+// rcloneOptionSpecs are the rclone options volumes may set
+var rcloneOptionSpecs = []OptionSpec{
+	{Name: "read-only", Kind: OptionFlag},
+	{Name: "no-modtime", Kind: OptionFlag},
+	{Name: "no-checksum", Kind: OptionFlag},
+	{Name: "vfs-fast-fingerprint", Kind: OptionFlag},
+	{Name: "vfs-cache-max-age", Kind: OptionDuration},
+	{Name: "vfs-cache-poll-interval", Kind: OptionDuration},
+	{Name: "vfs-write-back", Kind: OptionDuration},
+	{Name: "dir-cache-time", Kind: OptionDuration},
+	{Name: "poll-interval", Kind: OptionDuration},
+	{Name: "attr-timeout", Kind: OptionDuration},
+	{Name: "vfs-read-chunk-size", Kind: OptionSize},
+	{Name: "vfs-read-chunk-size-limit", Kind: OptionSize},
+	{Name: "vfs-read-ahead", Kind: OptionSize},
+	{Name: "max-read-ahead", Kind: OptionSize},
+	{Name: "buffer-size", Kind: OptionSize},
+	{Name: "s3-chunk-size", Kind: OptionSize},
+	{Name: "s3-upload-concurrency", Kind: OptionInt},
+	{Name: "transfers", Kind: OptionInt},
+	{Name: "checkers", Kind: OptionInt},
+	{Name: "s3-storage-class", Kind: OptionEnum, Values: storageClasses},
+	{Name: "s3-acl", Kind: OptionEnum, Values: cannedACLs},
+	{Name: "log-level", Kind: OptionEnum, Values: []string{"DEBUG", "INFO", "NOTICE", "ERROR"}},
+}
+
+func init() {
+	Register(&Backend{
+		Name:             "rclone",
+		Binary:           rcloneCmd,
+		New:              NewRcloneMounter,
+		TranslateOptions: rcloneOptions,
+		Options:          rcloneOptionSpecs,
+		CheckAvailable:   BinaryAvailable(rcloneCmd),
+		FSType:           "fuse.rclone",
+		LimitsCache:      true,
+	})
+}
+
+type RcloneMounter struct {
+	fuseMounter
+	meta *utils.Metadata
+	cfg  *utils.Config
+}
+
+// NewRcloneMounter creates a new rclone mounter
+func NewRcloneMounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
+	if meta.VFSCacheMode != "" && !slices.Contains(rcloneVFSCacheModes, meta.VFSCacheMode) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid rclone vfs cache mode %q, must be one of %v", meta.VFSCacheMode, rcloneVFSCacheModes)
+	}
+	return &RcloneMounter{
+		fuseMounter: fuseMounter{binary: rcloneCmd},
+		meta:        meta,
+		cfg:         cfg,
+	}, nil
+}
+
+// Mount mounts the bucket with rclone.
+// The credentials are passed in a per-volume config file instead of the command line.
+func (r *RcloneMounter) Mount(ctx context.Context, target, volumeID string) error {
+	configFile, err := writeCredentialFile(target, ".rclone.conf", r.config())
+	if err != nil {
+		return err
+	}
+	if err := FuseMount(ctx, target, rcloneCmd, r.args(target, configFile), nil); err != nil {
+		if rmErr := removeCredentialFiles(ctx, target); rmErr != nil {
+			klog.FromContext(ctx).Error(rmErr, "Failed to remove the rclone config", "target", target)
+		}
+		return err
+	}
+	return nil
+}
+
+// args returns the rclone arguments for mounting the volume at target
+func (r *RcloneMounter) args(target, configFile string) []string {
+	vfsCacheMode := r.meta.VFSCacheMode
+	if vfsCacheMode == "" {
+		vfsCacheMode = defaultVFSCacheMode
+	}
+	args := []string{
+		"mount",
+		fmt.Sprintf("%s:%s", rcloneRemote, path.Join(r.meta.BucketName, r.meta.Prefix)),
+		target,
+		"--config", configFile,
+		"--allow-other",
+		"--vfs-cache-mode", vfsCacheMode,
+	}
+	return append(args, rcloneOptions(r.meta)...)
+}
+
+// config returns the rclone config of the volume
+func (r *RcloneMounter) config() string {
+	provider := r.cfg.Provider
+	if provider == "" {
+		provider = defaultRcloneProvider
+	}
+	lines := []string{
+		fmt.Sprintf("[%s]", rcloneRemote),
+		"type = s3",
+		fmt.Sprintf("provider = %s", provider),
+		"env_auth = false",
+		fmt.Sprintf("access_key_id = %s", r.cfg.AccessKeyID),
+		fmt.Sprintf("secret_access_key = %s", r.cfg.SecretAccessKey),
+		fmt.Sprintf("endpoint = %s", r.cfg.Endpoint),
+	}
+	if r.cfg.Region != "" {
+		lines = append(lines, fmt.Sprintf("region = %s", r.cfg.Region))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// rcloneOptions translates the generic volume options into rclone arguments.
+func rcloneOptions(meta *utils.Metadata) []string {
+	var args []string
+	if meta.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	if meta.MountPermissions != 0 {
+		// rclone has no separate mode for the mount point
+		args = append(args, "--dir-perms", fmt.Sprintf("%o", meta.MountPermissions))
+	}
+	if meta.UID != nil {
+		args = append(args, "--uid", fmt.Sprintf("%d", *meta.UID))
+	}
+	if meta.GID != nil {
+		args = append(args, "--gid", fmt.Sprintf("%d", *meta.GID))
+	}
+	if meta.Umask != nil {
+		args = append(args, "--umask", fmt.Sprintf("%03o", *meta.Umask))
+	}
+	if meta.CacheDir != "" {
+		args = append(args, "--cache-dir", meta.CacheDir)
+		if meta.CacheSize > 0 {
+			args = append(args, "--vfs-cache-max-size", fmt.Sprintf("%dB", meta.CacheSize))
+		}
+	}
+	args = append(args, meta.MountFlags...)
+	return append(args, meta.MountOptions...)
+}
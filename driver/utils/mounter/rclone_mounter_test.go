@@ -0,0 +1,102 @@
+package mounter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 11:41:19
+ * @file: rclone_mounter_test.go
+ * @description: rclone_mounter 单测
+ */
+
+func TestRcloneMounterArgs(t *testing.T) {
+	uid := uint32(1000)
+	cfg := &utils.Config{
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "SECRET",
+		Endpoint:        "https://s3.example.com",
+		Region:          "us-east-1",
+	}
+	tests := []struct {
+		name string
+		meta *utils.Metadata
+		want []string
+	}{
+		{
+			name: "Test default vfs cache mode",
+			meta: &utils.Metadata{BucketName: "bucket"},
+			want: []string{"mount", "s3:bucket", "/target", "--config", "/conf",
+				"--allow-other", "--vfs-cache-mode", "writes"},
+		},
+		{
+			name: "Test prefix, vfs cache mode and ownership",
+			meta: &utils.Metadata{BucketName: "bucket", Prefix: "pvc-1", VFSCacheMode: "full", UID: &uid},
+			want: []string{"mount", "s3:bucket/pvc-1", "/target", "--config", "/conf",
+				"--allow-other", "--vfs-cache-mode", "full", "--uid", "1000"},
+		},
+		{
+			name: "Test cache directory and size",
+			meta: &utils.Metadata{BucketName: "bucket", VFSCacheMode: "full", CacheDir: "/var/lib/s3-csi/cache/pvc-1", CacheSize: 1 << 30},
+			want: []string{"mount", "s3:bucket", "/target", "--config", "/conf",
+				"--allow-other", "--vfs-cache-mode", "full",
+				"--cache-dir", "/var/lib/s3-csi/cache/pvc-1", "--vfs-cache-max-size", "1073741824B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewRcloneMounter(tt.meta, cfg)
+			if err != nil {
+				t.Fatalf("NewRcloneMounter() error = %v", err)
+			}
+			got := m.(*RcloneMounter).args("/target", "/conf")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+			for _, arg := range got {
+				if strings.Contains(arg, cfg.SecretAccessKey) || strings.Contains(arg, cfg.AccessKeyID) {
+					t.Errorf("args() exposes credentials: %v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestRcloneMounterConfig(t *testing.T) {
+	m, err := NewRcloneMounter(&utils.Metadata{BucketName: "bucket"}, &utils.Config{
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "SECRET",
+		Endpoint:        "https://s3.example.com",
+		Provider:        "Minio",
+	})
+	if err != nil {
+		t.Fatalf("NewRcloneMounter() error = %v", err)
+	}
+	want := "[s3]\ntype = s3\nprovider = Minio\nenv_auth = false\naccess_key_id = AKID\nsecret_access_key = SECRET\nendpoint = https://s3.example.com\n"
+	if got := m.(*RcloneMounter).config(); got != want {
+		t.Errorf("config() = %q, want %q", got, want)
+	}
+}
+
+func TestNewRcloneMounterInvalidCacheMode(t *testing.T) {
+	if _, err := NewRcloneMounter(&utils.Metadata{VFSCacheMode: "all"}, &utils.Config{}); err == nil {
+		t.Errorf("NewRcloneMounter() expected error for invalid vfs cache mode")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"s3fs", "rclone"} {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("Lookup(%q) backend is not registered", name)
+		}
+	}
+	if _, err := NewMounter(&utils.Metadata{Mounter: "unknown"}, &utils.Config{}); err == nil {
+		t.Errorf("NewMounter() expected error for unknown mounter")
+	}
+}
@@ -0,0 +1,149 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 11:02:47
+ * @file: registry.go
+ * @description: 挂载器注册表
+ */
+
+// DefaultMounter is the mounter used when neither the volume nor the secrets select one.
+const DefaultMounter = "s3fs"
+
+// Backend describes a FUSE mounter implementation which can be
+// selected through the mounter key of the volume context.
+type Backend struct {
+	// Name is the value of the mounter key selecting the backend.
+	Name string
+	// Binary is the executable of the FUSE process.
+	Binary string
+	// New creates a Mounter for the volume.
+	New func(meta *utils.Metadata, cfg *utils.Config) (Mounter, error)
+	// TranslateOptions translates the generic volume options into arguments of the backend.
+	TranslateOptions func(meta *utils.Metadata) []string
+	// CheckAvailable returns an error if the backend cannot be used on this node.
+	CheckAvailable func() error
+	// Options is the schema of the options the backend accepts in the options of a volume.
+	// Options managed by the driver, like credentials and the cache, are not part of it.
+	Options []OptionSpec
+	// LimitsCache is set if the backend keeps its cache within the cache size by itself,
+	// otherwise the driver evicts files of the cache.
+	LimitsCache bool
+	// FSType is the filesystem type of the mounts of the backend in the mount table, like fuse.s3fs.
+	// Empty accepts any FUSE filesystem type.
+	FSType string
+	// MountTimeout is how long the backend may take to mount, 0 means DefaultMountTimeout.
+	MountTimeout time.Duration
+	// ValidateCapability returns an error for volume capabilities the backend cannot support,
+	// nil means that every capability supported by the driver is supported.
+	ValidateCapability func(ctx context.Context, capability *csi.VolumeCapability) error
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]*Backend)
+	// defaultMounter replaces DefaultMounter as configured by the administrator
+	defaultMounter = DefaultMounter
+)
+
+// SetDefaultMounter sets the mounter used when neither the volume nor the secrets select one.
+func SetDefaultMounter(name string) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	defaultMounter = name
+}
+
+// defaultMounterName returns the mounter used when neither the volume nor the secrets select one.
+func defaultMounterName() string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return defaultMounter
+}
+
+// Register makes a backend available by its name.
+// It panics if the backend is incomplete or its name is already registered.
+func Register(b *Backend) {
+	if b == nil || b.Name == "" || b.Binary == "" || b.New == nil || b.TranslateOptions == nil || b.CheckAvailable == nil {
+		panic("mounter: Register called with an incomplete backend")
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, exists := backends[b.Name]; exists {
+		panic(fmt.Sprintf("mounter: Register called twice for backend %s", b.Name))
+	}
+	backends[b.Name] = b
+}
+
+// Lookup returns the backend registered with the given name.
+func Lookup(name string) (*Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	b, ok := backends[name]
+	return b, ok
+}
+
+// Backends returns the sorted names of all registered backends.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsBackendBinary returns true if binary is the executable of a registered backend.
+func IsBackendBinary(binary string) bool {
+	return backendForBinary(binary) != nil
+}
+
+// backendForBinary returns the backend whose FUSE process is binary, nil if there is none.
+func backendForBinary(binary string) *Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	for _, backend := range backends {
+		if backend.Binary == binary {
+			return backend
+		}
+	}
+	return nil
+}
+
+// ValidateCapability checks the volume capability against the backend selected by mounterType.
+// Unknown backends are left to NewMounter to report.
+func ValidateCapability(ctx context.Context, mounterType string, capability *csi.VolumeCapability) error {
+	if mounterType == "" {
+		mounterType = defaultMounterName()
+	}
+	backend, ok := Lookup(mounterType)
+	if !ok || backend.ValidateCapability == nil {
+		return nil
+	}
+	return backend.ValidateCapability(ctx, capability)
+}
+
+// BinaryAvailable returns an availability check which succeeds if the binary is found in $PATH.
+func BinaryAvailable(binary string) func() error {
+	return func() error {
+		if _, err := exec.LookPath(binary); err != nil {
+			return fmt.Errorf("%s binary is not available: %w", binary, err)
+		}
+		return nil
+	}
+}
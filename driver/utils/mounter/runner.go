@@ -0,0 +1,65 @@
+package mounter
+
+import (
+	"context"
+	"sync"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 18:20:44
+ * @file: runner.go
+ * @description: FUSE进程运行器，可由本地supervisor或挂载守护进程实现
+ */
+
+// ProcessStatus describes the FUSE process serving a mount point
+type ProcessStatus struct {
+	Target   string `json:"target"`
+	Command  string `json:"command"`
+	Pid      int    `json:"pid"`
+	Exited   bool   `json:"exited"`
+	ExitCode int    `json:"exitCode"`
+	Restarts int    `json:"restarts"`
+}
+
+// ProcessRunner runs the FUSE processes serving the mount points.
+// The local Supervisor runs them as children of the driver, the mount helper
+// client runs them in a separate daemon which outlives restarts of the driver.
+type ProcessRunner interface {
+	// Start runs the command serving the mount at target, the process outlives ctx.
+	Start(ctx context.Context, target, command string, args, envs []string) error
+	// Stop stops the process serving target after its mount is gone and forgets it.
+	Stop(ctx context.Context, target string) error
+	// Status returns the status of the process serving target, nil if there is none.
+	Status(target string) (*ProcessStatus, error)
+	// List returns the status of all processes, a remote runner gives up when ctx is done.
+	List(ctx context.Context) ([]ProcessStatus, error)
+	// Tail returns the last lines of output of the process serving target.
+	Tail(target string) ([]string, error)
+	// Stats returns the I/O counters of the running process serving target.
+	Stats(target string) (*Stats, error)
+}
+
+var (
+	runnerMu sync.RWMutex
+	runner   ProcessRunner = supervisor
+)
+
+// SetRunner replaces the runner of the FUSE processes, by default they are run by the local supervisor
+func SetRunner(r ProcessRunner) {
+	runnerMu.Lock()
+	defer runnerMu.Unlock()
+	runner = r
+}
+
+// currentRunner returns the runner of the FUSE processes
+func currentRunner() ProcessRunner {
+	runnerMu.RLock()
+	defer runnerMu.RUnlock()
+	return runner
+}
+
+// ListProcesses returns the status of all FUSE processes known to the runner
+func ListProcesses(ctx context.Context) ([]ProcessStatus, error) {
+	return currentRunner().List(ctx)
+}
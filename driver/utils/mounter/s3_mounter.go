@@ -1,10 +1,11 @@
 package mounter
 
 import (
+	"context"
 	"fmt"
-	"os"
 
 	"github.com/keington/s3-csi-driver/driver/utils"
+	"k8s.io/klog/v2"
 )
 
 /**
@@ -14,7 +15,52 @@ import (
  * @description: s3挂载器
  */
 
+// s3fsCmd is the s3fs binary
+const s3fsCmd = "s3fs"
+
+// s3fsOptionSpecs are the s3fs options volumes may set
+var s3fsOptionSpecs = []OptionSpec{
+	{Name: "use_path_request_style", Kind: OptionFlag, Fuse: true},
+	{Name: "sigv2", Kind: OptionFlag, Fuse: true},
+	{Name: "sigv4", Kind: OptionFlag, Fuse: true},
+	{Name: "nomultipart", Kind: OptionFlag, Fuse: true},
+	{Name: "notsup_compat_dir", Kind: OptionFlag, Fuse: true},
+	{Name: "compat_dir", Kind: OptionFlag, Fuse: true},
+	{Name: "complement_stat", Kind: OptionFlag, Fuse: true},
+	{Name: "enable_noobj_cache", Kind: OptionFlag, Fuse: true},
+	{Name: "nonempty", Kind: OptionFlag, Fuse: true},
+	{Name: "kernel_cache", Kind: OptionFlag, Fuse: true},
+	{Name: "multipart_size", Kind: OptionInt, Fuse: true},
+	{Name: "parallel_count", Kind: OptionInt, Fuse: true},
+	{Name: "multireq_max", Kind: OptionInt, Fuse: true},
+	{Name: "max_stat_cache_size", Kind: OptionInt, Fuse: true},
+	{Name: "stat_cache_expire", Kind: OptionInt, Fuse: true},
+	{Name: "retries", Kind: OptionInt, Fuse: true},
+	{Name: "connect_timeout", Kind: OptionInt, Fuse: true},
+	{Name: "readwrite_timeout", Kind: OptionInt, Fuse: true},
+	{Name: "list_object_max_keys", Kind: OptionInt, Fuse: true},
+	{Name: "max_dirty_data", Kind: OptionInt, Fuse: true},
+	{Name: "ensure_diskfree", Kind: OptionInt, Fuse: true},
+	{Name: "max_background", Kind: OptionInt, Fuse: true},
+	{Name: "dbglevel", Kind: OptionEnum, Values: []string{"crit", "err", "warn", "info", "debug"}, Fuse: true},
+	{Name: "storage_class", Kind: OptionEnum, Values: storageClasses, Fuse: true},
+	{Name: "default_acl", Kind: OptionEnum, Values: cannedACLs, Fuse: true},
+}
+
+func init() {
+	Register(&Backend{
+		Name:             "s3fs",
+		Binary:           s3fsCmd,
+		New:              NewS3Mounter,
+		TranslateOptions: s3fsOptions,
+		Options:          s3fsOptionSpecs,
+		CheckAvailable:   BinaryAvailable(s3fsCmd),
+		FSType:           "fuse.s3fs",
+	})
+}
+
 type S3Mounter struct {
+	fuseMounter
 	meta          *utils.Metadata
 	url           string
 	region        string
@@ -24,6 +70,7 @@ type S3Mounter struct {
 // NewS3Mounter creates a new S3 fs mounter
 func NewS3Mounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
 	return &S3Mounter{
+		fuseMounter:   fuseMounter{binary: s3fsCmd},
 		meta:          meta,
 		url:           cfg.Endpoint,
 		region:        cfg.Region,
@@ -32,36 +79,61 @@ func NewS3Mounter(meta *utils.Metadata, cfg *utils.Config) (Mounter, error) {
 }
 
 // Mount mounts the s3fs
-func (s *S3Mounter) Mount(target, volumeID string) error {
-
-	if err := writeS3Pass(s.pwFileContent); err != nil {
+func (s *S3Mounter) Mount(ctx context.Context, target, volumeID string) error {
+	pwFile, err := writeCredentialFile(target, ".passwd-s3fs", s.pwFileContent)
+	if err != nil {
+		return err
+	}
+	if err := FuseMount(ctx, target, s3fsCmd, s.args(target, pwFile), nil); err != nil {
+		if rmErr := removeCredentialFiles(ctx, target); rmErr != nil {
+			klog.FromContext(ctx).Error(rmErr, "Failed to remove the credential file", "target", target)
+		}
 		return err
 	}
+	return nil
+}
+
+// args returns the s3fs arguments for mounting the volume at target
+func (s *S3Mounter) args(target, pwFile string) []string {
 	args := []string{
 		fmt.Sprintf("%s:%s", s.meta.BucketName, s.meta.Prefix),
 		target,
+		"-f",
+		"-o", fmt.Sprintf("passwd_file=%s", pwFile),
 		"-o", "use_path_request_style",
 		"-o", fmt.Sprintf("url=%s", s.url),
 		"-o", "allow_other",
-		"-o", "mp_umask=000",
 	}
 	if s.region != "" {
 		args = append(args, "-o", fmt.Sprintf("endpoint=%s", s.region))
 	}
-	return FuseMount(target, "s3fs", args, nil)
+	return append(args, s3fsOptions(s.meta)...)
 }
 
-// writeS3Pass writes the s3 password to a file
-func writeS3Pass(pwFileContent string) error {
-	pwFileName := fmt.Sprintf("%s/.passwd-s3", os.Getenv("HOME"))
-	pwFile, err := os.OpenFile(pwFileName, os.O_RDWR|os.O_CREATE, 0600)
-	if err != nil {
-		return err
+// s3fsOptions translates the generic volume options into s3fs arguments.
+// Without explicit mount permissions the mount point stays world accessible (mp_umask=000).
+func s3fsOptions(meta *utils.Metadata) []string {
+	var mpUmask uint64
+	if meta.MountPermissions != 0 {
+		mpUmask = 0777 &^ meta.MountPermissions
 	}
-	_, err = pwFile.WriteString(pwFileContent)
-	if err != nil {
-		return err
+	args := []string{"-o", fmt.Sprintf("mp_umask=%03o", mpUmask)}
+	if meta.ReadOnly {
+		args = append(args, "-o", "ro")
 	}
-	pwFile.Close()
-	return nil
+	if meta.UID != nil {
+		args = append(args, "-o", fmt.Sprintf("uid=%d", *meta.UID))
+	}
+	if meta.GID != nil {
+		args = append(args, "-o", fmt.Sprintf("gid=%d", *meta.GID))
+	}
+	if meta.Umask != nil {
+		args = append(args, "-o", fmt.Sprintf("umask=%03o", *meta.Umask))
+	}
+	if meta.CacheDir != "" {
+		// s3fs cannot limit its cache, the driver evicts files instead
+		args = append(args, "-o", "use_cache="+meta.CacheDir, "-o", "del_cache")
+	}
+	args = append(args, meta.MountFlags...)
+	return append(args, meta.MountOptions...)
 }
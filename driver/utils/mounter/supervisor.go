@@ -0,0 +1,447 @@
+package mounter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 17:21:09
+ * @file: supervisor.go
+ * @description: 前台运行FUSE进程并按策略重启
+ */
+
+// RestartPolicy controls whether a FUSE process is restarted after it exited unexpectedly
+type RestartPolicy string
+
+const (
+	// RestartNever leaves exited FUSE processes alone
+	RestartNever RestartPolicy = "Never"
+	// RestartOnFailure restarts FUSE processes which exited with a non-zero code
+	RestartOnFailure RestartPolicy = "OnFailure"
+	// RestartAlways restarts FUSE processes whenever they exit unexpectedly
+	RestartAlways RestartPolicy = "Always"
+)
+
+const (
+	// maxRestartBackoff caps the delay between restarts of a FUSE process
+	maxRestartBackoff = 30 * time.Second
+)
+
+// SupervisorOptions configures the FUSE process supervisor
+type SupervisorOptions struct {
+	LogDir        string        // LogDir holds the per-mount log files.
+	LogMaxSize    int64         // LogMaxSize is the size in bytes at which a log file is rotated.
+	LogMaxFiles   int           // LogMaxFiles is the number of rotated log files kept per mount.
+	TailLines     int           // TailLines is the number of log lines attached to mount errors.
+	RestartPolicy RestartPolicy // RestartPolicy decides whether exited processes are restarted.
+	MaxRestarts   int           // MaxRestarts limits the restarts of a process, 0 means unlimited.
+}
+
+// DefaultSupervisorOptions returns the supervisor options used unless configured otherwise.
+// There is no default LogDir, the driver keeps the logs under its WorkingMountDir.
+func DefaultSupervisorOptions() SupervisorOptions {
+	return SupervisorOptions{
+		LogMaxSize:    10 << 20,
+		LogMaxFiles:   3,
+		TailLines:     20,
+		RestartPolicy: RestartOnFailure,
+		MaxRestarts:   5,
+	}
+}
+
+// Supervisor runs FUSE processes in the foreground, captures their output
+// and restarts them according to the restart policy.
+type Supervisor struct {
+	mu    sync.Mutex
+	opts  SupervisorOptions
+	procs map[string]*supervisedProcess
+}
+
+// supervisedProcess is a FUSE process serving one mount point
+type supervisedProcess struct {
+	target  string
+	command string
+	args    []string
+	envs    []string
+	log     *rotatingLog
+	// logger is the logger of the call which started the process, it carries the volume ID
+	logger klog.Logger
+
+	cmd      *exec.Cmd
+	exited   chan struct{} // closed when the current run of the process exited
+	exitCode int
+	restarts int
+	stopping bool
+}
+
+var supervisor = NewSupervisor(DefaultSupervisorOptions())
+
+// ConfigureSupervisor replaces the options of the supervisor, running processes keep their logs
+func ConfigureSupervisor(opts SupervisorOptions) {
+	supervisor.mu.Lock()
+	defer supervisor.mu.Unlock()
+	supervisor.opts = opts
+}
+
+// NewSupervisor creates a new supervisor
+func NewSupervisor(opts SupervisorOptions) *Supervisor {
+	return &Supervisor{
+		opts:  opts,
+		procs: make(map[string]*supervisedProcess),
+	}
+}
+
+// Start runs the command serving the mount at target under supervision
+func (s *Supervisor) Start(ctx context.Context, target, command string, args, envs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.procs[target]; ok && !p.hasExited() {
+		return fmt.Errorf("a %s process is already serving %s", p.command, target)
+	}
+	if s.opts.LogDir == "" {
+		return fmt.Errorf("no log directory is configured for the %s process of %s", command, target)
+	}
+
+	log, err := newRotatingLog(filepath.Join(s.opts.LogDir, mountID(target)+".log"), s.opts.LogMaxSize, s.opts.LogMaxFiles, s.opts.TailLines)
+	if err != nil {
+		return fmt.Errorf("failed to open log of %s: %w", target, err)
+	}
+	p := &supervisedProcess{
+		target:  target,
+		command: command,
+		args:    args,
+		envs:    envs,
+		log:     log,
+		logger:  klog.LoggerWithValues(klog.FromContext(ctx), "command", command, "target", target),
+	}
+	if err := p.launch(); err != nil {
+		log.Close()
+		return err
+	}
+	s.procs[target] = p
+	go s.supervise(p)
+	return nil
+}
+
+// Stop stops the process serving target after its mount is gone and forgets it.
+// The process gets some time to exit by itself before it is terminated.
+func (s *Supervisor) Stop(ctx context.Context, target string) error {
+	s.mu.Lock()
+	p, ok := s.procs[target]
+	if !ok {
+		s.mu.Unlock()
+		return nil
+	}
+	p.stopping = true
+	exited, process := p.exited, p.cmd.Process
+	s.mu.Unlock()
+
+	logger := klog.FromContext(ctx)
+	if !waitForExit(exited, fuseExitTimeout) {
+		logger.Info("FUSE process did not exit after unmount, sending SIGTERM", "command", p.command, "pid", process.Pid, "target", target)
+		if err := process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		if !waitForExit(exited, fuseKillTimeout) {
+			logger.Info("FUSE process did not exit after SIGTERM, sending SIGKILL", "command", p.command, "pid", process.Pid, "target", target)
+			if err := process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+				return err
+			}
+			if !waitForExit(exited, fuseKillTimeout) {
+				return fmt.Errorf("process %d did not exit after SIGKILL", process.Pid)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	if s.procs[target] == p {
+		delete(s.procs, target)
+	}
+	s.mu.Unlock()
+	if p.exitCode == 0 {
+		// keep the logs of failed processes for troubleshooting
+		return p.log.Remove()
+	}
+	return p.log.Close()
+}
+
+// Status returns the status of the process serving target, nil if there is none
+func (s *Supervisor) Status(target string) (*ProcessStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.procs[target]
+	if !ok {
+		return nil, nil
+	}
+	st := p.status()
+	return &st, nil
+}
+
+// List returns the status of all supervised processes
+func (s *Supervisor) List(context.Context) ([]ProcessStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]ProcessStatus, 0, len(s.procs))
+	for _, p := range s.procs {
+		list = append(list, p.status())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Target < list[j].Target })
+	return list, nil
+}
+
+// Tail returns the last lines of output of the process serving target
+func (s *Supervisor) Tail(target string) ([]string, error) {
+	s.mu.Lock()
+	p, ok := s.procs[target]
+	s.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	return p.log.Tail(), nil
+}
+
+// Stats returns the I/O counters of the running process serving target
+func (s *Supervisor) Stats(target string) (*Stats, error) {
+	s.mu.Lock()
+	p, ok := s.procs[target]
+	running := ok && !p.hasExited()
+	var pid int
+	if running {
+		pid = p.cmd.Process.Pid
+	}
+	s.mu.Unlock()
+	if !running {
+		return nil, fmt.Errorf("no process is serving %s", target)
+	}
+	return readProcessStats(pid)
+}
+
+// supervise waits for the process to exit and restarts it according to the policy
+func (s *Supervisor) supervise(p *supervisedProcess) {
+	// binds are the bind mounts of a dead mount of the process, which still wait to be bound again
+	var binds []bindMount
+	for {
+		err := p.cmd.Wait()
+		exitCode := p.cmd.ProcessState.ExitCode()
+
+		s.mu.Lock()
+		p.exitCode = exitCode
+		close(p.exited)
+		stopping := p.stopping
+		restart := !stopping && s.shouldRestart(p, exitCode)
+		backoff := restartBackoff(p.restarts)
+		if restart {
+			p.restarts++
+		}
+		s.mu.Unlock()
+
+		if stopping {
+			p.log.Printf("process exited with code %d after unmount", exitCode)
+			return
+		}
+		p.log.Printf("process exited unexpectedly with code %d: %v", exitCode, err)
+		p.logger.Error(err, "FUSE process exited unexpectedly", "exitCode", exitCode)
+		if !restart {
+			return
+		}
+
+		time.Sleep(backoff)
+		// the dead process leaves a disconnected FUSE mount behind. The bind mounts publishing it
+		// stay disconnected as well until they are bound to the mount of the restarted process.
+		ctx := klog.NewContext(context.Background(), p.logger)
+		if found, err := findBindMounts(p.target); err != nil {
+			p.logger.Error(err, "Failed to look up the bind mounts")
+		} else if len(found) > 0 {
+			binds = found
+		}
+		if err := unmountPath(ctx, p.target); err != nil {
+			p.logger.Error(err, "Failed to unmount before restarting the FUSE process")
+		}
+
+		s.mu.Lock()
+		if p.stopping || s.procs[p.target] != p {
+			s.mu.Unlock()
+			return
+		}
+		p.log.Printf("restarting process, attempt %d", p.restarts)
+		p.logger.Info("Restarting FUSE process", "attempt", p.restarts)
+		err = p.launch()
+		s.mu.Unlock()
+		if err != nil {
+			p.log.Printf("failed to restart process: %v", err)
+			p.logger.Error(err, "Failed to restart FUSE process")
+			return
+		}
+		if len(binds) > 0 && s.rebind(ctx, p, binds) {
+			binds = nil
+		}
+	}
+}
+
+// bindMount is a bind mount of a directory of a FUSE mount
+type bindMount struct {
+	target string
+	// root is the bind mounted directory, relative to the FUSE mount
+	root     string
+	readOnly bool
+}
+
+// findBindMounts returns the bind mounts of the FUSE mount at target. They share its device,
+// which no other FUSE mount has.
+func findBindMounts(target string) ([]bindMount, error) {
+	infos, err := mount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	target = filepath.Clean(target)
+	var fuse *mount.MountInfo
+	for i := range infos {
+		if infos[i].MountPoint == target {
+			fuse = &infos[i]
+		}
+	}
+	if fuse == nil {
+		return nil, nil
+	}
+
+	var binds []bindMount
+	for _, info := range infos {
+		if info.MountPoint == target || info.Major != fuse.Major || info.Minor != fuse.Minor {
+			continue
+		}
+		root, err := filepath.Rel(fuse.Root, info.Root)
+		if err != nil || root == ".." || strings.HasPrefix(root, "../") {
+			continue
+		}
+		binds = append(binds, bindMount{target: info.MountPoint, root: root, readOnly: slices.Contains(info.MountOptions, "ro")})
+	}
+	return binds, nil
+}
+
+// rebind replaces the bind mounts of the dead mount of a restarted process by bind mounts of
+// its new mount, once it is ready. It returns false if the process did not mount in time.
+func (s *Supervisor) rebind(ctx context.Context, p *supervisedProcess, binds []bindMount) bool {
+	name := ""
+	if backend := backendForBinary(filepath.Base(p.command)); backend != nil {
+		name = backend.Name
+	}
+	ctx, cancel := context.WithTimeout(ctx, mountTimeout(name))
+	defer cancel()
+	if err := waitForMount(ctx, s, p.target, ""); err != nil {
+		p.log.Printf("not binding the %d bind mounts again: %v", len(binds), err)
+		p.logger.Error(err, "Restarted FUSE process did not mount, its bind mounts stay disconnected", "bindMounts", len(binds))
+		return false
+	}
+	s.mu.Lock()
+	stopped := p.stopping || s.procs[p.target] != p
+	s.mu.Unlock()
+	if stopped {
+		// the volume is being unstaged, its bind mounts are gone or go away with it
+		return true
+	}
+	for _, b := range binds {
+		options := []string{"bind"}
+		if b.readOnly {
+			options = append(options, "ro")
+		}
+		source := filepath.Join(p.target, b.root)
+		err := unmountPath(ctx, b.target)
+		if err == nil {
+			err = mountUtils.Mount(source, b.target, "", options)
+		}
+		if err != nil {
+			p.log.Printf("failed to bind %s to %s again: %v", source, b.target, err)
+			p.logger.Error(err, "Failed to bind mount again after restarting the FUSE process", "source", source, "bindTarget", b.target)
+			continue
+		}
+		p.log.Printf("bound %s to %s again", source, b.target)
+	}
+	return true
+}
+
+// status returns the status of the process, s.mu must be held
+func (p *supervisedProcess) status() ProcessStatus {
+	return ProcessStatus{
+		Target:   p.target,
+		Command:  p.command,
+		Pid:      p.cmd.Process.Pid,
+		Exited:   p.hasExited(),
+		ExitCode: p.exitCode,
+		Restarts: p.restarts,
+	}
+}
+
+// shouldRestart applies the restart policy, s.mu must be held
+func (s *Supervisor) shouldRestart(p *supervisedProcess, exitCode int) bool {
+	if s.opts.MaxRestarts > 0 && p.restarts >= s.opts.MaxRestarts {
+		return false
+	}
+	switch s.opts.RestartPolicy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	default:
+		return false
+	}
+}
+
+// launch starts a new run of the process
+func (p *supervisedProcess) launch() error {
+	cmd := exec.Command(p.command, p.args...)
+	cmd.Stdout = p.log
+	cmd.Stderr = p.log
+	// cmd.Environ() returns envs inherited from the current process
+	cmd.Env = append(cmd.Environ(), p.envs...)
+	p.log.Printf("starting %s for %s", p.command, p.target)
+	if err := cmd.Start(); err != nil {
+		p.log.Printf("failed to start %s: %v", p.command, err)
+		return fmt.Errorf("failed to start %s: %w", p.command, err)
+	}
+	p.cmd = cmd
+	p.exited = make(chan struct{})
+	p.exitCode = 0
+	return nil
+}
+
+// hasExited returns true if the current run of the process exited
+func (p *supervisedProcess) hasExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// restartBackoff returns the delay before the given restart attempt
+func restartBackoff(restarts int) time.Duration {
+	backoff := time.Second << min(restarts, 5)
+	return min(backoff, maxRestartBackoff)
+}
+
+// waitForExit returns true if exited is closed within the timeout
+func waitForExit(exited <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
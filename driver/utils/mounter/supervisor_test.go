@@ -0,0 +1,202 @@
+package mounter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils/testutil"
+
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/ktesting"
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 17:58:03
+ * @file: supervisor_test.go
+ * @description: supervisor 单测
+ */
+
+// waitForStatus polls the supervisor until the process of target exited
+func waitForStatus(t *testing.T, s *Supervisor, target string) int {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if st, _ := s.Status(target); st != nil && st.Exited {
+			return st.ExitCode
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("process of %s did not exit", target)
+	return 0
+}
+
+func TestSupervisorCapturesOutput(t *testing.T) {
+	opts := DefaultSupervisorOptions()
+	opts.LogDir = t.TempDir()
+	opts.RestartPolicy = RestartNever
+	s := NewSupervisor(opts)
+
+	target := "/staging/output"
+	if err := s.Start(context.Background(), target, "sh", []string{"-c", "echo mounting; echo failed to connect >&2; exit 3"}, nil); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if exitCode := waitForStatus(t, s, target); exitCode != 3 {
+		t.Errorf("Status() exit code = %d, want 3", exitCode)
+	}
+
+	tail, err := s.Tail(target)
+	if err != nil {
+		t.Fatalf("Tail() error = %v", err)
+	}
+	for _, line := range []string{"mounting", "failed to connect"} {
+		if !slices.Contains(tail, line) {
+			t.Errorf("Tail() = %q, missing %q", tail, line)
+		}
+	}
+	content, err := os.ReadFile(filepath.Join(opts.LogDir, mountID(target)+".log"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(content), "failed to connect") {
+		t.Errorf("log file does not contain the process output: %q", content)
+	}
+
+	if err := s.Stop(context.Background(), target); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if st, _ := s.Status(target); st != nil {
+		t.Errorf("Status() still knows %s after Stop()", target)
+	}
+}
+
+func TestSupervisorRestartsOnFailure(t *testing.T) {
+	opts := DefaultSupervisorOptions()
+	opts.LogDir = t.TempDir()
+	opts.RestartPolicy = RestartOnFailure
+	opts.MaxRestarts = 1
+	s := NewSupervisor(opts)
+
+	// the restarts are logged with the logger of the call which started the process
+	logger := ktesting.NewLogger(t, ktesting.NewConfig(ktesting.BufferLogs(true)))
+	ctx := klog.NewContext(context.Background(), klog.LoggerWithValues(logger, "volumeID", "bucket/pvc-1"))
+	target := filepath.Join(t.TempDir(), "restart")
+	if err := s.Start(ctx, target, "sh", []string{"-c", "echo run; exit 1"}, nil); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// the first restart is delayed by one second
+	runs := 0
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		runs = 0
+		tail, _ := s.Tail(target)
+		for _, line := range tail {
+			if line == "run" {
+				runs++
+			}
+		}
+		if st, _ := s.Status(target); runs == 2 && st.Exited {
+			break
+		}
+	}
+	if runs != 2 {
+		t.Errorf("process ran %d times, want 2", runs)
+	}
+	if err := s.Stop(context.Background(), target); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	logs := logger.GetSink().(ktesting.Underlier).GetBuffer().String()
+	if !strings.Contains(logs, "Restarting FUSE process") || !strings.Contains(logs, `volumeID="bucket/pvc-1"`) {
+		t.Errorf("restart was not logged with the volume ID:\n%s", logs)
+	}
+}
+
+func TestSupervisorRebindsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	target, published, subPath := filepath.Join(dir, "staging"), filepath.Join(dir, "published"), filepath.Join(dir, "subpath")
+	testutil.FakeMountInfo(t, &mountInfoPath,
+		testutil.Mount{MountPoint: target, Device: "0:60"},
+		testutil.Mount{MountPoint: published, Device: "0:60"},
+		testutil.Mount{MountPoint: subPath, Device: "0:60", Root: "/data", Options: "ro,relatime"},
+		testutil.Mount{MountPoint: filepath.Join(dir, "other"), Device: "0:61"},
+	)
+	fake := mount.NewFakeMounter(nil)
+	oldMountUtils := mountUtils
+	mountUtils = fake
+	defer func() { mountUtils = oldMountUtils }()
+
+	opts := DefaultSupervisorOptions()
+	opts.LogDir = t.TempDir()
+	opts.RestartPolicy = RestartOnFailure
+	opts.MaxRestarts = 1
+	s := NewSupervisor(opts)
+	if err := s.Start(context.Background(), target, "sh", []string{"-c", "exit 1"}, nil); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// the first restart is delayed by one second
+	want := [][2]string{{target, published}, {filepath.Join(target, "data"), subPath}}
+	var got [][2]string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && len(got) < len(want); time.Sleep(50 * time.Millisecond) {
+		got = nil
+		for _, action := range fake.GetLog() {
+			if action.Action == mount.FakeActionMount {
+				got = append(got, [2]string{action.Source, action.Target})
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bind mounts after the restart = %v, want %v", got, want)
+	}
+	mounts, _ := fake.List()
+	for _, m := range mounts {
+		if m.Path == subPath && !slices.Contains(m.Opts, "ro") {
+			t.Errorf("read-only bind mount %s was bound again with options %v", subPath, m.Opts)
+		}
+	}
+	if err := s.Stop(context.Background(), target); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+}
+
+func TestSupervisorRequiresLogDir(t *testing.T) {
+	s := NewSupervisor(DefaultSupervisorOptions())
+	if err := s.Start(context.Background(), "/staging/nolog", "true", nil, nil); err == nil {
+		t.Errorf("Start() without a log directory succeeded")
+	}
+}
+
+func TestRotatingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "volume.log")
+	l, err := newRotatingLog(path, 16, 1, 2)
+	if err != nil {
+		t.Fatalf("newRotatingLog() error = %v", err)
+	}
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "partial"} {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if got, want := l.Tail(), []string{"second line", "third line", "partial"}; !slices.Equal(got, want) {
+		t.Errorf("Tail() = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("log was not rotated: %v", err)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("more rotated files than configured are kept")
+	}
+	if err := l.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Remove() left %s behind", path)
+	}
+}
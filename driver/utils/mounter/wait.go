@@ -0,0 +1,137 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	mount "k8s.io/mount-utils"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 22:06:41
+ * @file: wait.go
+ * @description: 等待FUSE挂载就绪，超时可按挂载器配置并受请求截止时间约束
+ */
+
+const (
+	// DefaultMountTimeout is how long a backend without a mount timeout may take to mount
+	DefaultMountTimeout = 10 * time.Second
+	// mountPollInterval is the interval of the checks of the mount table while waiting for a mount
+	mountPollInterval = 50 * time.Millisecond
+)
+
+// mountInfoPath is the mount table of the driver, it is replaced in tests.
+var mountInfoPath = "/proc/self/mountinfo"
+
+var (
+	mountTimeoutsMu sync.RWMutex
+	// mountTimeouts overrides the mount timeouts by backend name, the empty name applies to all backends
+	mountTimeouts = map[string]time.Duration{}
+)
+
+// SetMountTimeouts overrides the mount timeouts of the backends, by backend name.
+// The timeout of the empty name applies to every backend without its own.
+func SetMountTimeouts(timeouts map[string]time.Duration) {
+	mountTimeoutsMu.Lock()
+	defer mountTimeoutsMu.Unlock()
+	mountTimeouts = timeouts
+}
+
+// ParseMountTimeouts parses a comma separated list of mount timeouts, each either a
+// duration for all backends or backend=duration, like 10s,rclone=1m.
+func ParseMountTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			name, value = "", item
+		} else if _, known := Lookup(name); !known {
+			return nil, fmt.Errorf("mount timeout for unknown mounter %s, available mounters: %v", name, Backends())
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid mount timeout %q", item)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// mountTimeout returns the mount timeout of the backend
+func mountTimeout(name string) time.Duration {
+	mountTimeoutsMu.RLock()
+	defer mountTimeoutsMu.RUnlock()
+	if timeout, ok := mountTimeouts[name]; ok {
+		return timeout
+	}
+	if timeout, ok := mountTimeouts[""]; ok {
+		return timeout
+	}
+	if backend, ok := Lookup(name); ok && backend.MountTimeout > 0 {
+		return backend.MountTimeout
+	}
+	return DefaultMountTimeout
+}
+
+// waitForMount waits until a FUSE filesystem of fsType is mounted at path, or any FUSE
+// filesystem if fsType is empty. It fails as soon as the process serving path exits,
+// or when ctx is done.
+func waitForMount(ctx context.Context, r ProcessRunner, path, fsType string) error {
+	ticker := time.NewTicker(mountPollInterval)
+	defer ticker.Stop()
+	for {
+		mounted, err := isFuseMounted(path, fsType)
+		if err != nil {
+			return err
+		}
+		if mounted {
+			return nil
+		}
+		st, err := r.Status(path)
+		if err != nil {
+			return fmt.Errorf("failed to look up the FUSE process of %s: %w", path, err)
+		}
+		if st != nil && st.Exited {
+			return fmt.Errorf("%s exited with code %d before %s was mounted", st.Command, st.ExitCode, path)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for %s to be mounted: %w", path, context.Cause(ctx))
+		case <-ticker.C:
+		}
+	}
+}
+
+// isFuseMounted returns true if the topmost mount at path is a FUSE filesystem of fsType,
+// or of any FUSE type if fsType is empty.
+func isFuseMounted(path, fsType string) (bool, error) {
+	infos, err := mount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return false, err
+	}
+	path = filepath.Clean(path)
+	var found *mount.MountInfo
+	for i := range infos {
+		// later entries are mounted on top of earlier ones
+		if infos[i].MountPoint == path {
+			found = &infos[i]
+		}
+	}
+	if found == nil {
+		return false, nil
+	}
+	if fsType != "" {
+		return found.FsType == fsType, nil
+	}
+	return found.FsType == "fuse" || strings.HasPrefix(found.FsType, "fuse."), nil
+}
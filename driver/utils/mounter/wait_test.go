@@ -0,0 +1,114 @@
+package mounter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils/testutil"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 22:21:37
+ * @file: wait_test.go
+ * @description: wait 单测
+ */
+
+func TestIsFuseMounted(t *testing.T) {
+	testutil.FakeMountInfo(t, &mountInfoPath,
+		testutil.Mount{MountPoint: "/staging/s3fs", FsType: "ext4"},
+		testutil.Mount{MountPoint: "/staging/s3fs", FsType: "fuse.s3fs"},
+		testutil.Mount{MountPoint: "/staging/mountpoint", FsType: "fuse"},
+		testutil.Mount{MountPoint: "/staging/disk", FsType: "ext4"},
+	)
+
+	tests := []struct {
+		name   string
+		path   string
+		fsType string
+		want   bool
+	}{
+		{name: "Test matching type", path: "/staging/s3fs/", fsType: "fuse.s3fs", want: true},
+		{name: "Test other backend", path: "/staging/s3fs", fsType: "fuse.rclone", want: false},
+		{name: "Test any FUSE type", path: "/staging/mountpoint", want: true},
+		{name: "Test not FUSE", path: "/staging/disk", want: false},
+		{name: "Test not mounted", path: "/staging/none", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isFuseMounted(tt.path, tt.fsType)
+			if err != nil {
+				t.Fatalf("isFuseMounted() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isFuseMounted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForMount(t *testing.T) {
+	testutil.FakeMountInfo(t, &mountInfoPath, testutil.Mount{MountPoint: "/staging/ready", FsType: "fuse.s3fs"})
+	opts := DefaultSupervisorOptions()
+	opts.LogDir = t.TempDir()
+	opts.RestartPolicy = RestartNever
+	s := NewSupervisor(opts)
+	// the processes never mount, do not wait for them to exit by themselves on Stop
+	oldExitTimeout := fuseExitTimeout
+	fuseExitTimeout = 0
+	defer func() { fuseExitTimeout = oldExitTimeout }()
+
+	if err := waitForMount(context.Background(), s, "/staging/ready", "fuse.s3fs"); err != nil {
+		t.Errorf("waitForMount() of a ready mount error = %v", err)
+	}
+
+	// an exited process fails the mount at once, not after the timeout
+	if err := s.Start(context.Background(), "/staging/exited", "sh", []string{"-c", "exit 4"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop(context.Background(), "/staging/exited")
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := waitForMount(ctx, s, "/staging/exited", "fuse.s3fs")
+	if err == nil || !strings.Contains(err.Error(), "exited with code 4") {
+		t.Errorf("waitForMount() error = %v, want exit of the process", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waitForMount() took %v to detect the exit", elapsed)
+	}
+
+	// the deadline of the request bounds the wait
+	if err := s.Start(context.Background(), "/staging/slow", "sleep", []string{"10"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop(context.Background(), "/staging/slow")
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := waitForMount(ctx, s, "/staging/slow", "fuse.s3fs"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waitForMount() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseMountTimeouts(t *testing.T) {
+	timeouts, err := ParseMountTimeouts("30s, rclone=1m")
+	if err != nil {
+		t.Fatalf("ParseMountTimeouts() error = %v", err)
+	}
+	SetMountTimeouts(timeouts)
+	defer SetMountTimeouts(nil)
+	for name, want := range map[string]time.Duration{"rclone": time.Minute, "s3fs": 30 * time.Second} {
+		if got := mountTimeout(name); got != want {
+			t.Errorf("mountTimeout(%s) = %v, want %v", name, got, want)
+		}
+	}
+
+	for _, s := range []string{"fast", "unknown=10s", "s3fs=-1s"} {
+		if _, err := ParseMountTimeouts(s); err == nil {
+			t.Errorf("ParseMountTimeouts(%q) expected error", s)
+		}
+	}
+}
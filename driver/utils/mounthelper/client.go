@@ -0,0 +1,113 @@
+package mounthelper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 19:05:26
+ * @file: client.go
+ * @description: 挂载守护进程客户端，作为节点服务的FUSE进程运行器
+ */
+
+// callTimeout bounds the calls to the mount helper, stopping a FUSE process may take a while
+const callTimeout = time.Minute
+
+// Client runs the FUSE processes of the node plugin in the mount helper.
+// It implements mounter.ProcessRunner.
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+var _ mounter.ProcessRunner = &Client{}
+
+// Dial connects to the mount helper listening on the unix socket of endpoint.
+// The connection is established lazily, so the helper may start after the node plugin.
+func Dial(endpoint string) (*Client, error) {
+	proto, addr, err := utils.ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if proto != "unix" {
+		return nil, fmt.Errorf("mount helper endpoint %s must be a unix socket", endpoint)
+	}
+	conn, err := grpc.Dial("unix://"+utils.UnixSocketPath(addr),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mount helper %s: %w", endpoint, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the connection to the mount helper
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Start asks the mount helper to run the command serving the mount at target
+func (c *Client) Start(ctx context.Context, target, command string, args, envs []string) error {
+	return c.invokeContext(ctx, "Mount", &MountRequest{Target: target, Command: command, Args: args, Envs: envs}, &Empty{})
+}
+
+// Stop asks the mount helper to stop the process serving target
+func (c *Client) Stop(ctx context.Context, target string) error {
+	return c.invokeContext(ctx, "Unmount", &TargetRequest{Target: target}, &Empty{})
+}
+
+// Status returns the status of the process serving target, nil if there is none
+func (c *Client) Status(target string) (*mounter.ProcessStatus, error) {
+	resp := &StatusResponse{}
+	if err := c.invoke("Status", &TargetRequest{Target: target}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// List returns the status of all processes run by the mount helper
+func (c *Client) List(ctx context.Context) ([]mounter.ProcessStatus, error) {
+	resp := &ListResponse{}
+	if err := c.invokeContext(ctx, "List", &Empty{}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Processes, nil
+}
+
+// Tail returns the last lines of output of the process serving target
+func (c *Client) Tail(target string) ([]string, error) {
+	resp := &TailResponse{}
+	if err := c.invoke("Tail", &TargetRequest{Target: target}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Lines, nil
+}
+
+// Stats returns the I/O counters of the process serving target
+func (c *Client) Stats(target string) (*mounter.Stats, error) {
+	resp := &StatsResponse{}
+	if err := c.invoke("Stats", &TargetRequest{Target: target}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
+
+// invoke calls a method of the mount helper
+func (c *Client) invoke(method string, req, resp interface{}) error {
+	return c.invokeContext(context.Background(), method, req, resp)
+}
+
+// invokeContext calls a method of the mount helper, giving up when ctx is done
+func (c *Client) invokeContext(ctx context.Context, method string, req, resp interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	return c.conn.Invoke(ctx, "/"+serviceName+"/"+method, req, resp)
+}
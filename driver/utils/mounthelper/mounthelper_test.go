@@ -0,0 +1,188 @@
+package mounthelper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 19:16:48
+ * @file: mounthelper_test.go
+ * @description: 挂载守护进程单测
+ */
+
+// fakeRunner records the processes instead of running them
+type fakeRunner struct {
+	mu    sync.Mutex
+	procs map[string]*mounter.ProcessStatus
+	envs  map[string][]string
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{procs: make(map[string]*mounter.ProcessStatus), envs: make(map[string][]string)}
+}
+
+func (f *fakeRunner) Start(_ context.Context, target, command string, _, envs []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.procs[target] = &mounter.ProcessStatus{Target: target, Command: command, Pid: 42}
+	f.envs[target] = envs
+	return nil
+}
+
+func (f *fakeRunner) Stop(_ context.Context, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.procs, target)
+	return nil
+}
+
+func (f *fakeRunner) Status(target string) (*mounter.ProcessStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.procs[target], nil
+}
+
+func (f *fakeRunner) List(context.Context) ([]mounter.ProcessStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []mounter.ProcessStatus
+	for _, st := range f.procs {
+		list = append(list, *st)
+	}
+	return list, nil
+}
+
+func (f *fakeRunner) Tail(target string) ([]string, error) {
+	return []string{"output of " + target}, nil
+}
+
+func (f *fakeRunner) Stats(target string) (*mounter.Stats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, ok := f.procs[target]
+	if !ok {
+		return nil, fmt.Errorf("no process is serving %s", target)
+	}
+	return &mounter.Stats{PID: st.Pid, ReadBytes: 4096}, nil
+}
+
+func TestMountHelper(t *testing.T) {
+	// unix socket paths are limited to about 100 characters
+	dir, err := os.MkdirTemp("", "mount-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	endpoint := "unix://" + filepath.Join(dir, "helper.sock")
+
+	listener, err := Listen(endpoint)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	runner := newFakeRunner()
+	server := NewServer(runner)
+	go server.server.Serve(listener)
+	defer server.Stop()
+
+	client, err := Dial(endpoint)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	target := "/var/lib/kubelet/plugins/kubernetes.io/csi/s3.csi.k8s.io/globalmount"
+	envs := []string{"AWS_ACCESS_KEY_ID=AKID"}
+	if err := client.Start(context.Background(), target, "s3fs", []string{"bucket", target, "-f"}, envs); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if !reflect.DeepEqual(runner.envs[target], envs) {
+		t.Errorf("Start() passed envs %v, want %v", runner.envs[target], envs)
+	}
+	if err := client.Start(context.Background(), "/target", "/bin/sh", nil, nil); err == nil {
+		t.Errorf("Start() expected error for a binary which is not a mounter backend")
+	}
+
+	st, err := client.Status(target)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if want := (&mounter.ProcessStatus{Target: target, Command: "s3fs", Pid: 42}); !reflect.DeepEqual(st, want) {
+		t.Errorf("Status() = %+v, want %+v", st, want)
+	}
+	list, err := client.List(context.Background())
+	if err != nil || len(list) != 1 {
+		t.Errorf("List() = %v, %v, want one process", list, err)
+	}
+	// a probe gives up with its context instead of waiting for the call timeout
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.List(canceled); err == nil {
+		t.Errorf("List() expected error for a canceled context")
+	}
+	if lines, err := client.Tail(target); err != nil || !reflect.DeepEqual(lines, []string{"output of " + target}) {
+		t.Errorf("Tail() = %v, %v", lines, err)
+	}
+	if stats, err := client.Stats(target); err != nil || stats.ReadBytes != 4096 {
+		t.Errorf("Stats() = %+v, %v", stats, err)
+	}
+
+	if err := client.Stop(context.Background(), target); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if st, err := client.Status(target); err != nil || st != nil {
+		t.Errorf("Status() after Stop() = %+v, %v, want nil", st, err)
+	}
+	if _, err := client.Stats(target); err == nil {
+		t.Errorf("Stats() expected error after Stop()")
+	}
+}
+
+func TestEndpointStyles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mount-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "helper.sock")
+
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{name: "Test absolute socket path", endpoint: "unix://" + socket},
+		// the style of the CSI endpoint, unix://tmp/csi.sock
+		{name: "Test CSI endpoint style", endpoint: "unix:/" + socket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := Listen(tt.endpoint)
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			if got := listener.Addr().String(); got != socket {
+				t.Errorf("Listen() created %s, want %s", got, socket)
+			}
+			server := NewServer(newFakeRunner())
+			go server.server.Serve(listener)
+			defer server.Stop()
+
+			client, err := Dial(tt.endpoint)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer client.Close()
+			if _, err := client.List(context.Background()); err != nil {
+				t.Errorf("List() error = %v", err)
+			}
+		})
+	}
+}
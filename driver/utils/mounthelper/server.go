@@ -0,0 +1,146 @@
+package mounthelper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/keington/s3-csi-driver/driver/utils"
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 18:52:10
+ * @file: server.go
+ * @description: 挂载守护进程服务端，持有FUSE进程，不随驱动重启
+ */
+
+// Server serves the mount helper API on a unix socket. The FUSE processes
+// are run by its runner, so they outlive restarts of the node plugin.
+type Server struct {
+	runner mounter.ProcessRunner
+	server *grpc.Server
+}
+
+// NewServer creates a mount helper server running the FUSE processes with runner
+func NewServer(runner mounter.ProcessRunner) *Server {
+	s := &Server{runner: runner}
+	s.server = grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}), grpc.UnaryInterceptor(logCall))
+	s.server.RegisterService(&serviceDesc, s)
+	return s
+}
+
+// Serve listens on the unix socket of endpoint and serves requests until Stop is called
+func (s *Server) Serve(endpoint string) error {
+	listener, err := Listen(endpoint)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Mount helper listening for connections on address: %#v", listener.Addr())
+	return s.server.Serve(listener)
+}
+
+// Stop stops serving requests, the FUSE processes keep running
+func (s *Server) Stop() {
+	s.server.GracefulStop()
+}
+
+// Listen creates the unix socket of endpoint, only accessible by its owner
+func Listen(endpoint string) (net.Listener, error) {
+	proto, addr, err := utils.ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if proto != "unix" {
+		return nil, fmt.Errorf("mount helper endpoint %s must be a unix socket", endpoint)
+	}
+	addr = utils.UnixSocketPath(addr)
+	if err := os.MkdirAll(filepath.Dir(addr), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove %s: %w", addr, err)
+	}
+	listener, err := net.Listen(proto, addr)
+	if err != nil {
+		return nil, err
+	}
+	// the requests carry credentials
+	if err := os.Chmod(addr, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func (s *Server) mount(ctx context.Context, req *MountRequest) (*Empty, error) {
+	if req.Target == "" {
+		return nil, status.Error(codes.InvalidArgument, "target missing in request")
+	}
+	// only FUSE processes may be started, not arbitrary commands
+	if !mounter.IsBackendBinary(req.Command) {
+		return nil, status.Errorf(codes.InvalidArgument, "%s is not the binary of a mounter backend", req.Command)
+	}
+	if err := s.runner.Start(ctx, req.Target, req.Command, req.Args, req.Envs); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) unmount(ctx context.Context, req *TargetRequest) (*Empty, error) {
+	if err := s.runner.Stop(ctx, req.Target); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) status(_ context.Context, req *TargetRequest) (*StatusResponse, error) {
+	st, err := s.runner.Status(req.Target)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &StatusResponse{Status: st}, nil
+}
+
+func (s *Server) list(ctx context.Context, _ *Empty) (*ListResponse, error) {
+	processes, err := s.runner.List(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &ListResponse{Processes: processes}, nil
+}
+
+func (s *Server) tail(_ context.Context, req *TargetRequest) (*TailResponse, error) {
+	lines, err := s.runner.Tail(req.Target)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &TailResponse{Lines: lines}, nil
+}
+
+func (s *Server) stats(_ context.Context, req *TargetRequest) (*StatsResponse, error) {
+	stats, err := s.runner.Stats(req.Target)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &StatsResponse{Stats: stats}, nil
+}
+
+// logCall logs the calls of the mount helper. Unlike LogGRPC it never logs
+// the requests, the environment of the FUSE processes holds credentials.
+func logCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	klog.V(4).Infof("Mount helper call: %s", info.FullMethod)
+	resp, err := handler(ctx, req)
+	if err != nil {
+		klog.Errorf("Mount helper call %s failed: %v", info.FullMethod, err)
+	}
+	return resp, err
+}
@@ -0,0 +1,110 @@
+package mounthelper
+
+import (
+	"context"
+	"encoding/json"
+
+	mounter "github.com/keington/s3-csi-driver/driver/utils/mounter"
+
+	"google.golang.org/grpc"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 18:41:37
+ * @file: service.go
+ * @description: 挂载守护进程的gRPC服务定义，消息以JSON编码
+ */
+
+// DefaultEndpoint is the default unix socket of the mount helper
+const DefaultEndpoint = "unix:///run/s3-csi/mount-helper.sock"
+
+// serviceName is the gRPC service served by the mount helper
+const serviceName = "s3csi.mounthelper.v1.MountHelper"
+
+// MountRequest asks the mount helper to run the FUSE process serving Target
+type MountRequest struct {
+	Target  string   `json:"target"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Envs    []string `json:"envs"`
+}
+
+// TargetRequest addresses the FUSE process serving Target
+type TargetRequest struct {
+	Target string `json:"target"`
+}
+
+// Empty is the request and response of calls without arguments or results
+type Empty struct{}
+
+// StatusResponse is the status of a FUSE process, Status is nil if there is none
+type StatusResponse struct {
+	Status *mounter.ProcessStatus `json:"status,omitempty"`
+}
+
+// ListResponse lists all FUSE processes of the mount helper
+type ListResponse struct {
+	Processes []mounter.ProcessStatus `json:"processes"`
+}
+
+// TailResponse holds the last lines of output of a FUSE process
+type TailResponse struct {
+	Lines []string `json:"lines"`
+}
+
+// StatsResponse holds the I/O counters of a FUSE process
+type StatsResponse struct {
+	Stats *mounter.Stats `json:"stats"`
+}
+
+// jsonCodec encodes the messages of the mount helper as JSON, so the API needs no generated code
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+// serviceDesc describes the mount helper service, it is implemented by *Server
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Mount", (*Server).mount),
+		unaryMethod("Unmount", (*Server).unmount),
+		unaryMethod("Status", (*Server).status),
+		unaryMethod("List", (*Server).list),
+		unaryMethod("Tail", (*Server).tail),
+		unaryMethod("Stats", (*Server).stats),
+	},
+	Metadata: "mounthelper",
+}
+
+// unaryMethod returns the description of a unary method handled by call
+func unaryMethod[Req, Resp any](name string, call func(*Server, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(*Server), ctx, req.(*Req))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
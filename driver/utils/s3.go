@@ -2,12 +2,17 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/keington/s3-csi-driver/driver/utils/metrics"
+	"github.com/keington/s3-csi-driver/driver/utils/tracing"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/klog/v2"
 	"net/url"
 	"sync/atomic"
+	"time"
 )
 
 /**
@@ -17,6 +22,17 @@ import (
  * @description: s3客户端
  */
 
+// DefaultDeleteParallelism is the number of objects removed at once when they are deleted one by one.
+const DefaultDeleteParallelism = 16
+
+// deleteParallelism is the number of objects removed at once when they are deleted one by one
+var deleteParallelism int64 = DefaultDeleteParallelism
+
+// SetDeleteParallelism sets the number of objects removed at once when they are deleted one by one.
+func SetDeleteParallelism(n int) {
+	atomic.StoreInt64(&deleteParallelism, int64(n))
+}
+
 // S3Client is a client
 type S3Client struct {
 	Config *Config
@@ -30,7 +46,25 @@ type Metadata struct {
 	Prefix        string   `json:"Prefix"`
 	Mounter       string   `json:"Mounter"`
 	MountOptions  []string `json:"MountOptions"`
+	MountFlags    []string `json:"MountFlags"`
 	CapacityBytes int64    `json:"CapacityBytes"`
+	// MountPermissions is the permission mode of the mount point, 0 keeps the mounter default.
+	MountPermissions uint64  `json:"MountPermissions"`
+	UID              *uint32 `json:"UID,omitempty"`
+	GID              *uint32 `json:"GID,omitempty"`
+	Umask            *uint32 `json:"Umask,omitempty"`
+	// VFSCacheMode is the rclone --vfs-cache-mode of the volume.
+	VFSCacheMode string `json:"VFSCacheMode"`
+	// CacheDir is the local directory used by the mounter for data caching, empty disables it.
+	CacheDir string `json:"CacheDir"`
+	// CacheSize is the size limit of CacheDir in bytes.
+	CacheSize int64 `json:"CacheSize"`
+	// AllowDelete allows mountpoint-s3 to delete objects.
+	AllowDelete bool `json:"AllowDelete"`
+	// AllowOverwrite allows mountpoint-s3 to overwrite existing objects.
+	AllowOverwrite bool `json:"AllowOverwrite"`
+	// ReadOnly mounts the bucket read-only, it is used when the FUSE mount is published directly.
+	ReadOnly bool `json:"ReadOnly"`
 }
 
 // Config holds values to configure the driver
@@ -39,12 +73,17 @@ type Config struct {
 	SecretAccessKey string
 	Region          string
 	Endpoint        string
+	Provider        string
 	Mounter         string
 }
 
-// NewS3Client creates a new S3Client
-func NewS3Client(cfg *Config) (*S3Client, error) {
-	var client = &S3Client{}
+// NewS3Client creates a new S3Client. Its requests are traced as part of ctx,
+// but they are not canceled with ctx, so that a deletion is not interrupted halfway.
+// A shutdown of the driver stops them at the next checkpoint.
+func NewS3Client(ctx context.Context, cfg *Config) (client *S3Client, err error) {
+	_, span := tracing.Start(ctx, "NewS3Client", attribute.String("endpoint", cfg.Endpoint))
+	defer func() { tracing.End(span, err) }()
+	client = &S3Client{}
 
 	client.Config = cfg
 	u, err := url.Parse(client.Config.Endpoint)
@@ -69,25 +108,30 @@ func NewS3Client(cfg *Config) (*S3Client, error) {
 	}
 
 	client.Minio = minioClient
-	client.Ctx = context.Background()
+	client.Ctx = DetachUntilShutdown(ctx)
 	return client, nil
 }
 
 // NewClientFromSecrets creates a new S3Client from secrets
-func NewClientFromSecrets(secrets map[string]string) (*S3Client, error) {
+func NewClientFromSecrets(ctx context.Context, secrets map[string]string) (*S3Client, error) {
 	cfg := &Config{
 		AccessKeyID:     secrets["accessKeyID"],
 		SecretAccessKey: secrets["secretAccessKey"],
 		Region:          secrets["region"],
 		Endpoint:        secrets["endpoint"],
+		Provider:        secrets["provider"],
 		Mounter:         "",
 	}
-	return NewS3Client(cfg)
+	return NewS3Client(ctx, cfg)
 }
 
 // CreateBucket creates a new bucket
 func (c *S3Client) CreateBucket(bucketName string) error {
-	err := c.Minio.MakeBucket(c.Ctx, bucketName, minio.MakeBucketOptions{})
+	ctx, span := tracing.Start(c.Ctx, "S3Client.CreateBucket", attribute.String("bucket", bucketName))
+	start := time.Now()
+	err := c.Minio.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+	tracing.End(span, err)
+	metrics.ObserveS3("MakeBucket", start, err)
 	if err != nil {
 		return fmt.Errorf("CreateBucket: failed to create bucket %s: %w", bucketName, err)
 	}
@@ -96,7 +140,11 @@ func (c *S3Client) CreateBucket(bucketName string) error {
 
 // CreatePrefix creates a new prefix
 func (c *S3Client) CreatePrefix(bucketName, prefix string) error {
-	_, err := c.Minio.PutObject(c.Ctx, bucketName, prefix, nil, 0, minio.PutObjectOptions{})
+	ctx, span := tracing.Start(c.Ctx, "S3Client.CreatePrefix", attribute.String("bucket", bucketName), attribute.String("prefix", prefix))
+	start := time.Now()
+	_, err := c.Minio.PutObject(ctx, bucketName, prefix, nil, 0, minio.PutObjectOptions{})
+	tracing.End(span, err)
+	metrics.ObserveS3("PutObject", start, err)
 	if err != nil {
 		return fmt.Errorf("CreatePrefix: failed to create prefix %s: %w", prefix, err)
 	}
@@ -104,55 +152,78 @@ func (c *S3Client) CreatePrefix(bucketName, prefix string) error {
 }
 
 // DeleteBucket deletes a bucket
-func (c *S3Client) DeleteBucket(bucketName string) error {
-	var err error
-	if err := c.deleteObjects(bucketName, ""); err == nil {
-		return c.Minio.RemoveBucket(c.Ctx, bucketName)
+func (c *S3Client) DeleteBucket(bucketName string) (err error) {
+	ctx, span := tracing.Start(c.Ctx, "S3Client.DeleteBucket", attribute.String("bucket", bucketName))
+	defer func() { tracing.End(span, err) }()
+	if err := c.deleteObjects(ctx, bucketName, ""); err == nil {
+		return c.removeBucket(ctx, bucketName)
+	} else if errors.Is(err, ErrShuttingDown) {
+		return err
 	}
 
-	klog.Warningf("DeleteBucket: failed to delete bucket %s, will try deleteObjectsOneByOne", bucketName)
+	klog.FromContext(ctx).Info("Failed to delete the objects of the bucket in bulk, deleting them one by one", "bucket", bucketName)
 
-	if err = c.deleteObjectsOneByOne(bucketName, ""); err == nil {
-		return c.Minio.RemoveBucket(c.Ctx, bucketName)
+	if err = c.deleteObjectsOneByOne(ctx, bucketName, ""); err == nil {
+		return c.removeBucket(ctx, bucketName)
 	}
 
 	return err
 }
 
 // DeletePrefix deletes a prefix
-func (c *S3Client) DeletePrefix(bucketName, prefix string) error {
-	var err error
-	if err = c.Minio.RemoveObject(c.Ctx, bucketName, prefix, minio.RemoveObjectOptions{}); err != nil {
+func (c *S3Client) DeletePrefix(bucketName, prefix string) (err error) {
+	ctx, span := tracing.Start(c.Ctx, "S3Client.DeletePrefix", attribute.String("bucket", bucketName), attribute.String("prefix", prefix))
+	defer func() { tracing.End(span, err) }()
+	start := time.Now()
+	err = c.Minio.RemoveObject(ctx, bucketName, prefix, minio.RemoveObjectOptions{})
+	metrics.ObserveS3("RemoveObject", start, err)
+	if err != nil {
 		return fmt.Errorf("DeletePrefix: failed to delete prefix %s: %w", prefix, err)
 	}
 
-	klog.Warningf("DeletePrefix: prefix %s is deleted", prefix)
+	klog.FromContext(ctx).Info("Deleted prefix", "bucket", bucketName, "prefix", prefix)
 
-	if err = c.deleteObjectsOneByOne(bucketName, ""); err == nil {
-		return c.Minio.RemoveBucket(c.Ctx, bucketName)
+	if err = c.deleteObjectsOneByOne(ctx, bucketName, ""); err == nil {
+		return c.removeBucket(ctx, bucketName)
 	}
 	return err
 }
 
 // IsBucketExist checks if a bucket exists
 func (c *S3Client) IsBucketExist(bucketName string) (bool, error) {
-	_, err := c.Minio.BucketExists(c.Ctx, bucketName)
+	ctx, span := tracing.Start(c.Ctx, "S3Client.IsBucketExist", attribute.String("bucket", bucketName))
+	start := time.Now()
+	_, err := c.Minio.BucketExists(ctx, bucketName)
+	tracing.End(span, err)
+	metrics.ObserveS3("BucketExists", start, err)
 	if err != nil {
 		return false, fmt.Errorf("IsBucketExist: failed to check if bucket %s exists: %w", bucketName, err)
 	}
 	return true, nil
 }
 
+// removeBucket removes an empty bucket
+func (c *S3Client) removeBucket(ctx context.Context, bucketName string) error {
+	start := time.Now()
+	err := c.Minio.RemoveBucket(ctx, bucketName)
+	metrics.ObserveS3("RemoveBucket", start, err)
+	return err
+}
+
 // deleteObjects deletes all objects in a bucket
-func (c *S3Client) deleteObjects(bucketName, prefix string) error {
+func (c *S3Client) deleteObjects(ctx context.Context, bucketName, prefix string) (err error) {
+	ctx, span := tracing.Start(ctx, "S3Client.deleteObjects", attribute.String("bucket", bucketName), attribute.String("prefix", prefix))
+	defer func() { tracing.End(span, err) }()
 	objectsCh := make(chan minio.ObjectInfo)
 	var listErr error
 
 	go func() {
 		defer close(objectsCh)
 
+		start := time.Now()
+		defer func() { metrics.ObserveS3("ListObjects", start, listErr) }()
 		for object := range c.Minio.ListObjects(
-			c.Ctx,
+			ctx,
 			bucketName,
 			minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
 			if object.Err != nil {
@@ -164,29 +235,40 @@ func (c *S3Client) deleteObjects(bucketName, prefix string) error {
 	}()
 
 	if listErr != nil {
-		klog.Error("Error listing objects", listErr)
+		klog.FromContext(ctx).Error(listErr, "Failed to list objects", "bucket", bucketName, "prefix", prefix)
 		return listErr
 	}
 
 	opts := minio.RemoveObjectsOptions{
 		GovernanceBypass: true,
 	}
-	errorCh := c.Minio.RemoveObjects(c.Ctx, bucketName, objectsCh, opts)
+	start := time.Now()
+	errorCh := c.Minio.RemoveObjects(ctx, bucketName, objectsCh, opts)
 	haveErrWhenRemoveObjects := false
 	for e := range errorCh {
-		klog.Errorf("Failed to remove object %s, error: %s", e.ObjectName, e.Err)
+		if ctx.Err() != nil {
+			// the objects canceled by the shutdown are not failures
+			continue
+		}
+		klog.FromContext(ctx).Error(e.Err, "Failed to remove object", "bucket", bucketName, "object", e.ObjectName)
 		haveErrWhenRemoveObjects = true
 	}
+	if err := checkpoint(ctx, bucketName, prefix); err != nil {
+		return err
+	}
+	var removeErr error
 	if haveErrWhenRemoveObjects {
-		return fmt.Errorf("failed to remove all objects of bucket %s", bucketName)
+		removeErr = fmt.Errorf("failed to remove all objects of bucket %s", bucketName)
 	}
-
-	return nil
+	metrics.ObserveS3("RemoveObjects", start, removeErr)
+	return removeErr
 }
 
 // will delete files one by one without file lock
-func (c *S3Client) deleteObjectsOneByOne(bucketName, prefix string) error {
-	parallelism := 16
+func (c *S3Client) deleteObjectsOneByOne(ctx context.Context, bucketName, prefix string) (err error) {
+	ctx, span := tracing.Start(ctx, "S3Client.deleteObjectsOneByOne", attribute.String("bucket", bucketName), attribute.String("prefix", prefix))
+	defer func() { tracing.End(span, err) }()
+	parallelism := int(atomic.LoadInt64(&deleteParallelism))
 	objectsCh := make(chan minio.ObjectInfo, parallelism)
 	guardCh := make(chan int, parallelism)
 	var listErr error
@@ -196,7 +278,9 @@ func (c *S3Client) deleteObjectsOneByOne(bucketName, prefix string) error {
 	go func() {
 		defer close(objectsCh)
 
-		for object := range c.Minio.ListObjects(c.Ctx, bucketName,
+		start := time.Now()
+		defer func() { metrics.ObserveS3("ListObjects", start, listErr) }()
+		for object := range c.Minio.ListObjects(ctx, bucketName,
 			minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
 			if object.Err != nil {
 				listErr = object.Err
@@ -208,18 +292,27 @@ func (c *S3Client) deleteObjectsOneByOne(bucketName, prefix string) error {
 	}()
 
 	if listErr != nil {
-		klog.Error("Error listing objects", listErr)
+		klog.FromContext(ctx).Error(listErr, "Failed to list objects", "bucket", bucketName, "prefix", prefix)
 		return listErr
 	}
 
 	for object := range objectsCh {
+		if ctx.Err() != nil {
+			// stop at the checkpoint, the listing ends by itself
+			continue
+		}
 		guardCh <- 1
 		go func(obj minio.ObjectInfo) {
-			err := c.Minio.RemoveObject(c.Ctx, bucketName, obj.Key,
+			start := time.Now()
+			err := c.Minio.RemoveObject(ctx, bucketName, obj.Key,
 				minio.RemoveObjectOptions{VersionID: obj.VersionID})
+			metrics.ObserveS3("RemoveObject", start, err)
 			if err != nil {
-				klog.Errorf("Failed to remove object %s, error: %s", obj.Key, err)
+				klog.FromContext(ctx).Error(err, "Failed to remove object", "bucket", bucketName, "object", obj.Key)
 				atomic.AddInt64(&removeErrors, 1)
+			} else {
+				metrics.DeletedObjects.Inc()
+				metrics.DeletedBytes.Add(float64(obj.Size))
 			}
 			<-guardCh
 		}(object)
@@ -231,9 +324,22 @@ func (c *S3Client) deleteObjectsOneByOne(bucketName, prefix string) error {
 		<-guardCh
 	}
 
+	if err := checkpoint(ctx, bucketName, prefix); err != nil {
+		return err
+	}
 	if removeErrors > 0 {
 		return fmt.Errorf("failed to remove %v objects out of total %v of path %s", removeErrors, totalObjects, bucketName)
 	}
 
 	return nil
 }
+
+// checkpoint returns the error of a deletion stopped by a shutdown of the driver, nil if it
+// goes on. The objects deleted so far stay deleted, a retry of the deletion resumes with the rest.
+func checkpoint(ctx context.Context, bucketName, prefix string) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	klog.FromContext(ctx).Info("Deletion interrupted by shutdown, a retry resumes it", "bucket", bucketName, "prefix", prefix)
+	return fmt.Errorf("deletion of bucket %s prefix %q interrupted: %w", bucketName, prefix, ErrShuttingDown)
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 01:07:55
+ * @file: shutdown.go
+ * @description: 驱动退出信号，长时间操作据此在退出前保存进度
+ */
+
+// ErrShuttingDown is the cause of the operations given up because the driver is shutting down.
+var ErrShuttingDown = errors.New("the driver is shutting down")
+
+// shutdown is canceled with ErrShuttingDown once the in-flight calls must stop
+var shutdown, beginShutdown = context.WithCancelCause(context.Background())
+
+// BeginShutdown tells the long-running operations to stop at their next checkpoint,
+// leaving a state a retry after the restart of the driver resumes from.
+func BeginShutdown() {
+	beginShutdown(ErrShuttingDown)
+}
+
+// ShutdownContext returns a context which is done once the driver begins to shut down.
+func ShutdownContext() context.Context {
+	return shutdown
+}
+
+// DetachUntilShutdown returns a context carrying the values of ctx, e.g. its logger and span,
+// which is not canceled with ctx but when the driver begins to shut down.
+func DetachUntilShutdown(ctx context.Context) context.Context {
+	return shutdownContext{Context: context.WithoutCancel(ctx)}
+}
+
+// shutdownContext is only canceled by a shutdown of the driver
+type shutdownContext struct {
+	context.Context
+}
+
+// Deadline implements context.Context.
+func (shutdownContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done implements context.Context.
+func (shutdownContext) Done() <-chan struct{} {
+	return shutdown.Done()
+}
+
+// Err implements context.Context.
+func (shutdownContext) Err() error {
+	return shutdown.Err()
+}
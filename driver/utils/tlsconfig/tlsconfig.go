@@ -0,0 +1,154 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 02:58:14
+ * @file: tlsconfig.go
+ * @description: TCP 端点的 TLS 服务端配置，可校验客户端证书，证书轮换后自动重新加载
+ */
+
+// Options are the files of the TLS credentials of a server
+type Options struct {
+	// CertFile is the PEM certificate chain of the server.
+	CertFile string
+	// KeyFile is the PEM private key of the certificate.
+	KeyFile string
+	// ClientCAFile are the PEM certificates of the CAs of the clients, setting it requires
+	// the clients to present a certificate they signed.
+	ClientCAFile string
+}
+
+// NewServerConfig returns the TLS config of a server with the credentials of opts. The files are
+// checked for changes at every handshake and reloaded, so the new connections use rotated certificates.
+func NewServerConfig(opts Options) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("a TLS certificate and key are required")
+	}
+	r := &reloader{opts: opts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}, nil
+}
+
+// fileVersion identifies the content of a file by its modification time and size
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// reloader holds the config of the handshakes, loaded from the files of the options
+type reloader struct {
+	opts Options
+
+	mu sync.Mutex
+	// versions are the versions of the files the config is loaded from
+	versions []fileVersion
+	config   *tls.Config
+}
+
+// getConfigForClient returns the config of a handshake, reloaded if the files changed.
+// A failed reload keeps the current config and is retried by the next handshake,
+// a rotation may be in the middle of replacing the files.
+func (r *reloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, err := r.stat()
+	if err == nil && equalVersions(versions, r.versions) {
+		return r.config, nil
+	}
+	if err == nil {
+		err = r.load()
+	}
+	if err != nil {
+		klog.Warningf("Failed to reload the TLS credentials, keeping the current ones: %v", err)
+	}
+	return r.config, nil
+}
+
+// files returns the files of the credentials
+func (r *reloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+// stat returns the versions of the files of the credentials
+func (r *reloader) stat() ([]fileVersion, error) {
+	var versions []fileVersion
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, fileVersion{modTime: info.ModTime(), size: info.Size()})
+	}
+	return versions, nil
+}
+
+// load reads the credentials from the files
+func (r *reloader) load() error {
+	versions, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate %s: %w", r.opts.CertFile, err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse TLS certificate %s: %w", r.opts.CertFile, err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		// gRPC runs over HTTP/2
+		NextProtos: []string{"h2"},
+	}
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in client CA file %s", r.opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.config, r.versions = config, versions
+	klog.Infof("Loaded TLS certificate of %s valid until %v, client certificates required: %v", leaf.Subject, leaf.NotAfter, r.opts.ClientCAFile != "")
+	return nil
+}
+
+// equalVersions reports whether the files did not change
+func equalVersions(a, b []fileVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-19 03:12:40
+ * @file: tlsconfig_test.go
+ * @description: tlsconfig 单测
+ */
+
+// testCA signs the certificates of the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCA creates a self-signed CA
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf certificate with the serial number
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "s3-csi-driver"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes a file with a modification time after the one of its earlier content
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects to the listener and returns the serial number of the server certificate
+func handshake(listener net.Listener, config *tls.Config) (int64, error) {
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	// the server verifies the client certificate after the client finished its handshake
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestNewServerConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	start := time.Now().Add(-time.Minute)
+	certPEM, keyPEM := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM, start)
+	writeFile(t, keyFile, keyPEM, start)
+	writeFile(t, caFile, ca.pem, start)
+
+	config, err := NewServerConfig(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewServerConfig() error = %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientCertPEM, clientKeyPEM := ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a client without certificate is rejected
+	if _, err := handshake(listener, &tls.Config{RootCAs: roots}); err == nil {
+		t.Errorf("handshake without client certificate succeeded")
+	}
+
+	client := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}
+	serial, err := handshake(listener, client)
+	if err != nil || serial != 2 {
+		t.Fatalf("handshake() = %d, %v, want the certificate 2", serial, err)
+	}
+
+	// a half written rotation keeps the current certificate
+	certPEM, keyPEM = ca.issue(t, 4, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM, start.Add(time.Second))
+	if serial, err := handshake(listener, client); err != nil || serial != 2 {
+		t.Errorf("handshake() during the rotation = %d, %v, want the certificate 2", serial, err)
+	}
+
+	// the rotated certificate is served once its key is written
+	writeFile(t, keyFile, keyPEM, start.Add(time.Second))
+	if serial, err := handshake(listener, client); err != nil || serial != 4 {
+		t.Errorf("handshake() after the rotation = %d, %v, want the certificate 4", serial, err)
+	}
+}
+
+func TestNewServerConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	writeFile(t, invalid, []byte("not a certificate"), time.Now())
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Test missing key", opts: Options{CertFile: invalid}},
+		{name: "Test missing files", opts: Options{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}},
+		{name: "Test invalid certificate", opts: Options{CertFile: invalid, KeyFile: invalid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServerConfig(tt.opts); err == nil {
+				t.Errorf("NewServerConfig() accepted %+v", tt.opts)
+			}
+		})
+	}
+}
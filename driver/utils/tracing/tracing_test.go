@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/**
+ * @author: HuaiAn xu
+ * @date: 2026-10-18 23:58:12
+ * @file: tracing_test.go
+ * @description: tracing 单测
+ */
+
+// recordSpans records the spans ended during the test in memory
+func recordSpans(t *testing.T, ratio float64) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), Options{SampleRatio: ratio, ServiceName: "s3.csi.k8s.io"})
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(old)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestStartEnd(t *testing.T) {
+	exporter := recordSpans(t, 1)
+
+	ctx, parent := Start(context.Background(), "CreateVolume")
+	_, child := Start(ctx, "S3Client.CreateBucket", attribute.String("bucket", "pvc-1"))
+	End(child, errors.New("access denied"))
+	End(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	bucket, volume := spans[0], spans[1]
+	if bucket.Name != "S3Client.CreateBucket" || volume.Name != "CreateVolume" {
+		t.Fatalf("got spans %s and %s", bucket.Name, volume.Name)
+	}
+	if bucket.Parent.SpanID() != volume.SpanContext.SpanID() {
+		t.Errorf("span %s is not a child of %s", bucket.Name, volume.Name)
+	}
+	if bucket.Status.Code != codes.Error || bucket.Status.Description != "access denied" {
+		t.Errorf("span %s status = %v, want error", bucket.Name, bucket.Status)
+	}
+	if len(bucket.Events) != 1 {
+		t.Errorf("span %s has %d events, want the recorded error", bucket.Name, len(bucket.Events))
+	}
+	if volume.Status.Code != codes.Unset {
+		t.Errorf("span %s status = %v, want unset", volume.Name, volume.Status)
+	}
+	if got := volume.Resource.Attributes(); len(got) != 1 || got[0].Value.AsString() != "s3.csi.k8s.io" {
+		t.Errorf("resource attributes = %v, want the service name", got)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	exporter := recordSpans(t, 0)
+
+	ctx, parent := Start(context.Background(), "NodeStageVolume")
+	_, child := Start(ctx, "FuseMount")
+	End(child, nil)
+	End(parent, nil)
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("got %d spans, want none sampled", len(spans))
+	}
+
+	// the decision of a sampled remote parent is kept
+	remote := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	_, span := Start(remote, "NodePublishVolume")
+	End(span, nil)
+	if spans := exporter.GetSpans(); len(spans) != 1 {
+		t.Errorf("got %d spans, want the span of the sampled parent", len(spans))
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Test no endpoint", opts: Options{SampleRatio: 5}},
+		{name: "Test invalid ratio", opts: Options{Endpoint: "localhost:4317", SampleRatio: 1.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := otel.GetTracerProvider()
+			shutdown, err := Setup(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if otel.GetTracerProvider() != old {
+				t.Errorf("Setup() replaced the tracer provider")
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
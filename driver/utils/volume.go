@@ -11,6 +11,9 @@ import (
  * @description: 卷锁
  */
 
+// VolumeOperationInProgressFmt is the message of the error returned while another operation holds the lock of a volume.
+const VolumeOperationInProgressFmt = "operation already in progress for volume %s"
+
 type VolumeLocks struct {
 	lock map[string]struct{} //nolint:staticcheck
 	mu   sync.Mutex
@@ -45,6 +48,7 @@ func (v *VolumeLocks) IsLocked(volumeID string) bool {
 }
 
 // TryAcquire tries to acquire the lock for the volume. If the lock is already
+// held by another operation, it returns false.
 func (v *VolumeLocks) TryAcquire(volumeID string) bool {
 	v.mu.Lock()
 	defer v.mu.Unlock()